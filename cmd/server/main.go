@@ -1,35 +1,66 @@
 package main
 
 import (
+	"errors"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"blueowl-ble/internal/ble"
 	"blueowl-ble/internal/hardware"
+	"blueowl-ble/internal/version"
 )
 
 func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
-	slog.Info("BlueOwl Cam System Starting...")
+	hw, err := hardware.NewController()
+	if err != nil {
+		slog.Error("Failed to start hardware", "err", err)
+		os.Exit(1)
+	}
+	btServer := ble.NewServer(hw)
+
+	backend, root := hardware.Backend(hw)
+	slog.Info("BlueOwl Cam System Starting...",
+		"version", version.String(),
+		"backend", backend,
+		"root_path", root,
+		"features", btServer.Features())
 
-	hw := hardware.NewController()
 	if err := hw.Init(); err != nil {
 		slog.Error("Failed to initialize hardware", "err", err)
 		os.Exit(1)
 	}
 	defer hw.Close()
 
-	// Initialize BLE Server
-	btServer := ble.NewServer(hw)
+	// Start the BLE Server
 	if err := btServer.Start(); err != nil {
 		slog.Error("Failed to start BLE server", "err", err)
 		os.Exit(1)
 	}
 
+	// The debug HTTP endpoints are optional, and failing to serve them
+	// leaves BLE running
+	var httpServer *http.Server
+	if addr := os.Getenv(ble.HTTPEnv); addr != "" {
+		btServer.WebSocket = os.Getenv(ble.WSEnv) != ""
+		if origins := os.Getenv(ble.WSOriginsEnv); origins != "" {
+			btServer.WSOrigins = strings.Split(origins, ",")
+		}
+		httpServer = &http.Server{Addr: addr, Handler: btServer.Handler()}
+		go func() {
+			slog.Info("Serving HTTP", "addr", addr, "websocket", btServer.WebSocket)
+			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("HTTP server failed", "err", err)
+			}
+		}()
+	}
+
 	slog.Info("BlueOWL Controller Ready. Press Ctrl+C to exit.")
 
 	// Block forever until Ctrl+C (SIGINT)
@@ -38,4 +69,8 @@ func main() {
 	<-sigChan
 
 	slog.Info("Shutting down...")
+	if httpServer != nil {
+		httpServer.Close() // Event streams hang up rather than wait to drain
+	}
+	btServer.Stop() // Before hw.Close, so the last status still goes out
 }
@@ -0,0 +1,104 @@
+package ble
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// advCompanyID is the Bluetooth SIG id reserved for testing, used until the
+// product has its own. advVersion leads the payload so it can evolve.
+const (
+	advCompanyID = 0xFFFF
+	advVersion   = 1
+)
+
+// Manufacturer data layout: version u8 | flags u8 | battery_pct u8
+const (
+	advFlagRecording = 1 << 0
+	advFlagCharging  = 1 << 1
+)
+
+func advertisementOptions(payload []byte) bluetooth.AdvertisementOptions {
+	return bluetooth.AdvertisementOptions{
+		LocalName:    "BlueOWL",
+		ServiceUUIDs: []bluetooth.UUID{ServiceOwlUUID, ServiceBattery},
+		ManufacturerData: []bluetooth.ManufacturerDataElement{
+			{CompanyID: advCompanyID, Data: payload},
+		},
+	}
+}
+
+// advertisementPayload encodes the device state scanners can see without
+// connecting.
+func (s *Server) advertisementPayload() []byte {
+	var flags, battery uint8
+	if info, err := callWithTimeout(s.CallTimeout, s.HW.GetRecorderInfo); err == nil && info.FilenameTag != "" {
+		flags |= advFlagRecording
+	}
+	if st, err := callWithTimeout(s.CallTimeout, s.HW.GetBatteryStatus); err == nil {
+		battery = st.Percentage
+		if st.IsCharging {
+			flags |= advFlagCharging
+		}
+	}
+	return []byte{advVersion, flags, battery}
+}
+
+// UpdateAdvertisement re-advertises with the current device state. BlueZ
+// only takes new data through a fresh advertisement object, and each one
+// stays exported on D-Bus, so nothing is done while the data is unchanged;
+// this keeps it cheap to call on every status update.
+func (s *Server) UpdateAdvertisement() error {
+	payload := s.advertisementPayload()
+
+	s.advMu.Lock()
+	defer s.advMu.Unlock()
+
+	if s.adv == nil || bytes.Equal(payload, s.advData) {
+		return nil
+	}
+
+	// Not started means an earlier update failed to bring it back up,
+	// which is what this retries
+	if err := s.adv.Stop(); err != nil && !isAdvNotStarted(err) {
+		return err
+	}
+	if err := startAdvertisement(s.adv, payload); err != nil {
+		// Keep advertising what was there, stale beats undiscoverable
+		if rerr := startAdvertisement(s.adv, s.advData); rerr != nil {
+			slog.Error("[BLE] Failed to restore advertisement", "err", rerr)
+			s.advData = nil // Differs from any payload, so the next update retries
+		}
+		return err
+	}
+
+	s.advData = payload
+	slog.Info("[BLE] Advertisement updated", "data", payload)
+	return nil
+}
+
+// startAdvertisement configures a stopped advertisement with payload and
+// starts it.
+func startAdvertisement(adv *bluetooth.Advertisement, payload []byte) error {
+	if err := adv.Configure(advertisementOptions(payload)); err != nil {
+		return err
+	}
+	return adv.Start()
+}
+
+// isAdvNotStarted reports whether Stop failed because BlueZ has no such
+// advertisement registered. tinygo doesn't export that error, only its
+// message.
+func isAdvNotStarted(err error) bool {
+	return strings.HasSuffix(err.Error(), "advertisement is not started")
+}
+
+// refreshAdvertisement runs UpdateAdvertisement, logging a failure.
+func (s *Server) refreshAdvertisement() {
+	if err := s.UpdateAdvertisement(); err != nil {
+		slog.Error("[BLE] Failed to update advertisement", "err", err)
+	}
+}
@@ -0,0 +1,83 @@
+package ble
+
+import (
+	"encoding/binary"
+	"time"
+
+	"blueowl-ble/internal/hardware"
+)
+
+// chargePollInterval is how often the charger is checked between status
+// ticks, so plugging it in updates the battery characteristics promptly.
+const chargePollInterval = 5 * time.Second
+
+// Battery Power State (0x2A1A) packs four 2-bit fields, low bits first:
+// present | discharging | charging | level. Each field uses 3 for "yes"
+// (present, discharging, charging, critically low) and 2 for "no".
+const (
+	powerStateNo  = 2
+	powerStateYes = 3
+
+	// criticalBatteryPct is reported as a critically low level
+	criticalBatteryPct = 10
+)
+
+// batteryPowerState encodes a status as a Battery Power State byte. A full
+// battery on the charger is neither charging nor discharging.
+func batteryPowerState(st *hardware.BatteryStatus) byte {
+	charging, discharging := byte(powerStateNo), byte(powerStateYes)
+	if st.IsCharging {
+		discharging = powerStateNo
+		if st.Percentage < 100 {
+			charging = powerStateYes
+		}
+	}
+
+	level := byte(powerStateNo) // Good level
+	if st.Percentage < criticalBatteryPct {
+		level = powerStateYes
+	}
+
+	return powerStateYes | discharging<<2 | charging<<4 | level<<6
+}
+
+// updateBattery writes every battery characteristic from a status.
+func (s *Server) updateBattery(st *hardware.BatteryStatus) {
+	s.mu.Lock()
+	s.charging, s.chargingKnown = st.IsCharging, true
+	s.mu.Unlock()
+
+	s.write(&s.battHandle, []byte{st.Percentage})
+	s.write(&s.battPowerHandle, []byte{batteryPowerState(st)})
+	s.write(&s.battTimeHandle, binary.LittleEndian.AppendUint16(nil, st.EstimatedMins))
+}
+
+// notifyBattery writes the battery characteristics from a fresh status.
+func (s *Server) notifyBattery() {
+	if !s.hasSubscribers() {
+		return
+	}
+	if st, err := callWithTimeout(s.CallTimeout, s.HW.GetBatteryStatus); err == nil {
+		s.updateBattery(st)
+	}
+}
+
+// pollCharging updates the battery characteristics when the charger was
+// plugged in or out since they were last written.
+func (s *Server) pollCharging() {
+	if !s.hasSubscribers() {
+		return
+	}
+	st, err := callWithTimeout(s.CallTimeout, s.HW.GetBatteryStatus)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	changed := !s.chargingKnown || s.charging != st.IsCharging
+	s.mu.Unlock()
+
+	if changed {
+		s.updateBattery(st)
+	}
+}
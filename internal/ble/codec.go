@@ -0,0 +1,112 @@
+package ble
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"blueowl-ble/internal/hardware"
+)
+
+// PayloadFormat selects how status notifications are encoded.
+type PayloadFormat uint8
+
+const (
+	FormatJSON   PayloadFormat = iota // Default, verbose
+	FormatBinary                      // Fixed-layout little-endian structs
+)
+
+func (f PayloadFormat) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatBinary:
+		return "binary"
+	}
+	return fmt.Sprintf("format(%d)", uint8(f))
+}
+
+// encodePayload marshals a status payload in the given format.
+func encodePayload(format PayloadFormat, v any) ([]byte, error) {
+	if format == FormatBinary {
+		return encodeBinary(v)
+	}
+	return json.Marshal(v)
+}
+
+// Binary layouts (all little-endian):
+//
+//	RecStatusPayload:  seq u32 | flags u8 (bit0 recording, bit1 auto-restart, bit2 paused) | fps u8 | bitrate u32 | tag_len u8 | tag | err_len u8 | err |
+//	                   measured_fps_centi u16 | measured_bitrate u32 | dropped_frames u32 | encode_errors u32 | device_unix i64 |
+//	                   elapsed_secs u32
+//	WifiStatusPayload: seq u32 | flags u8 (bit0 connected) | ssid_len u8 | ssid | err_len u8 | err |
+//	                   state_len u8 | state | ip_len u8 | ip | rssi i8
+//	DiskStatusPayload: seq u32 | total_mb u32 | used_mb u32 | free_mb u32 | trash_mb u32 | flags u8 (bit0 read-only)
+//	BatteryStatus:     percentage u8 | flags u8 (bit0 charging) | estimated_mins u16
+func encodeBinary(v any) ([]byte, error) {
+	switch p := v.(type) {
+	case RecStatusPayload:
+		var flags uint8
+		if p.IsRecording {
+			flags |= 1
+		}
+		if p.AutoRestart {
+			flags |= 2
+		}
+		if p.Paused {
+			flags |= 4
+		}
+		buf := binary.LittleEndian.AppendUint32(nil, p.Seq)
+		buf = append(buf, flags, p.FPS)
+		buf = binary.LittleEndian.AppendUint32(buf, p.Bitrate)
+		buf = appendShortString(buf, p.Tag)
+		buf = appendShortString(buf, p.Error)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(p.MeasuredFPS*100))
+		buf = binary.LittleEndian.AppendUint32(buf, p.MeasuredBitrate)
+		buf = binary.LittleEndian.AppendUint32(buf, p.DroppedFrames)
+		buf = binary.LittleEndian.AppendUint32(buf, p.EncodeErrors)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(p.DeviceUnix))
+		return binary.LittleEndian.AppendUint32(buf, p.ElapsedSecs), nil
+
+	case WifiStatusPayload:
+		var flags uint8
+		if p.Connected {
+			flags |= 1
+		}
+		buf := binary.LittleEndian.AppendUint32(nil, p.Seq)
+		buf = appendShortString(append(buf, flags), p.SSID)
+		buf = appendShortString(buf, p.Error)
+		buf = appendShortString(buf, p.State)
+		buf = appendShortString(buf, p.IPAddress)
+		return append(buf, byte(p.RSSI)), nil
+
+	case DiskStatusPayload:
+		buf := binary.LittleEndian.AppendUint32(nil, p.Seq)
+		buf = binary.LittleEndian.AppendUint32(buf, p.TotalMB)
+		buf = binary.LittleEndian.AppendUint32(buf, p.UsedMB)
+		buf = binary.LittleEndian.AppendUint32(buf, p.FreeMB)
+		buf = binary.LittleEndian.AppendUint32(buf, p.TrashMB)
+		var flags uint8
+		if p.ReadOnly {
+			flags |= 1
+		}
+		return append(buf, flags), nil
+
+	case *hardware.BatteryStatus:
+		var flags uint8
+		if p.IsCharging {
+			flags |= 1
+		}
+		return binary.LittleEndian.AppendUint16([]byte{p.Percentage, flags}, p.EstimatedMins), nil
+	}
+	return nil, fmt.Errorf("no binary layout for %T", v)
+}
+
+// appendShortString appends a u8 length prefix and at most 255 bytes of str.
+func appendShortString(buf []byte, str string) []byte {
+	if len(str) > 255 {
+		str = str[:255]
+	}
+	buf = append(buf, uint8(len(str)))
+	return append(buf, str...)
+}
@@ -0,0 +1,81 @@
+package ble
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"log/slog"
+	"time"
+
+	"blueowl-ble/internal/hardware"
+)
+
+// CompressionHeader is the first frame of a compressed browser stream.
+// The deflate data follows in frames like any other (see writeChunked),
+// each of ChunkSize bytes but the last. The client collects them until it
+// has read Size bytes, inflates them and splits the result on newlines to
+// get the original frames. A frame of another size before then is the
+// JSON end of a stream cut short, or an error.
+type CompressionHeader struct {
+	Compressed string `json:"compressed"` // Always "deflate"
+	Frames     int    `json:"frames"`
+	RawSize    int    `json:"raw_size"`
+	Size       int    `json:"size"`
+	ChunkSize  int    `json:"chunk_size"`
+}
+
+// deflateFrames joins the frames with newlines and compresses them.
+func deflateFrames(frames [][]byte) (raw int, out []byte, err error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, f := range frames {
+		n, err := w.Write(append(f, '\n'))
+		if err != nil {
+			return 0, nil, err
+		}
+		raw += n
+	}
+
+	if err := w.Close(); err != nil {
+		return 0, nil, err
+	}
+	return raw, buf.Bytes(), nil
+}
+
+// writeCompressed sends the frames as a single deflate stream, announced by a
+// CompressionHeader frame, reporting progress on op. It reports whether
+// streamCut cut it short.
+func (s *Server) writeCompressed(ctx context.Context, op *hardware.OperationHandle, frames [][]byte, deadline time.Time) (cut bool) {
+	raw, data, err := deflateFrames(frames)
+	if err != nil {
+		slog.Error("[BLE] Failed to compress browser stream", "err", err)
+		s.writeChunked(&s.browserHandle, []byte(`{"error": "compression_failed"}`))
+		return false
+	}
+
+	header := CompressionHeader{
+		Compressed: "deflate",
+		Frames:     len(frames),
+		RawSize:    raw,
+		Size:       len(data),
+		ChunkSize:  maxFrameSize,
+	}
+	if s.writeFrames(ctx, op, binaryFrames(header, data), deadline) {
+		return true
+	}
+
+	saved := 0
+	if raw > 0 {
+		saved = 100 - len(data)*100/raw
+	}
+	slog.Info("[BLE] Browser stream compressed",
+		"frames", len(frames),
+		"raw_bytes", raw,
+		"compressed_bytes", len(data),
+		"saved_pct", saved)
+	return false
+}
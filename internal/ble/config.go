@@ -0,0 +1,82 @@
+package ble
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// frameReassemblyGap abandons a half-received message when the next
+// fragment is this late, so a client that gave up can start over.
+const frameReassemblyGap = 5 * time.Second
+
+var errBadFrame = errors.New("fragment doesn't continue a message")
+
+// frameReader reassembles a message written in fragments, framed like
+// writeChunked's: a u16 little-endian length, then the message.
+type frameReader struct {
+	buf  []byte
+	size int
+	last time.Time
+}
+
+// add appends a fragment and returns the message once it's complete.
+func (r *frameReader) add(fragment []byte) ([]byte, error) {
+	if r.buf != nil && time.Since(r.last) > frameReassemblyGap {
+		r.buf = nil
+	}
+	r.last = time.Now()
+
+	if r.buf == nil {
+		if len(fragment) < frameHeaderSize {
+			return nil, errBadFrame
+		}
+		r.size = int(binary.LittleEndian.Uint16(fragment))
+		r.buf = make([]byte, 0, r.size)
+		fragment = fragment[frameHeaderSize:]
+	}
+	if len(r.buf)+len(fragment) > r.size {
+		r.buf = nil
+		return nil, errBadFrame
+	}
+
+	r.buf = append(r.buf, fragment...)
+	if len(r.buf) < r.size {
+		return nil, nil
+	}
+	msg := r.buf
+	r.buf = nil
+	return msg, nil
+}
+
+// handleConfigImport collects a config written in fragments and imports it
+// once complete, notifying the outcome framed as the config was.
+func (s *Server) handleConfigImport(client bluetooth.Connection, offset int, value []byte) {
+	s.mu.Lock()
+	c := s.client(client)
+	if c.config == nil {
+		c.config = &frameReader{}
+	}
+	data, err := c.config.add(value)
+	s.mu.Unlock()
+
+	if err == nil && data == nil {
+		return // More fragments to come
+	}
+	if err == nil {
+		err = s.call(func() error { return s.HW.ImportConfig(data) })
+	}
+
+	result := CmdResult{Action: "import_config", OK: err == nil}
+	if err != nil {
+		slog.Error("[BLE] Config import failed", "err", err)
+		result.Error = err.Error()
+	}
+	if data, err := json.Marshal(result); err == nil {
+		s.writeChunked(&s.configHandle, data)
+	}
+}
@@ -0,0 +1,73 @@
+package ble
+
+import (
+	"log/slog"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// Connection parameters requested around browser streams: a short interval
+// while data flows, then back to one that saves power. BlueZ ignores these
+// requests (the central decides), other stacks may honour them.
+var (
+	transferConnParams = bluetooth.ConnectionParams{
+		MinInterval: bluetooth.NewDuration(7500 * time.Microsecond),
+		MaxInterval: bluetooth.NewDuration(15 * time.Millisecond),
+		Timeout:     bluetooth.NewDuration(4 * time.Second),
+	}
+	idleConnParams = bluetooth.ConnectionParams{
+		MinInterval: bluetooth.NewDuration(30 * time.Millisecond),
+		MaxInterval: bluetooth.NewDuration(50 * time.Millisecond),
+		Timeout:     bluetooth.NewDuration(6 * time.Second),
+	}
+)
+
+// connParamsRequester is the part of bluetooth.Device used to change the
+// parameters of a connection.
+type connParamsRequester interface {
+	RequestConnectionParams(params bluetooth.ConnectionParams) error
+}
+
+// beginTransfer favours throughput while the first of any concurrent
+// browser streams runs.
+func (s *Server) beginTransfer() {
+	s.mu.Lock()
+	s.transfers++
+	first := s.transfers == 1
+	s.mu.Unlock()
+
+	if first {
+		s.requestConnParams(transferConnParams)
+	}
+}
+
+// endTransfer relaxes the connection once the last browser stream ends.
+func (s *Server) endTransfer() {
+	s.mu.Lock()
+	s.transfers--
+	last := s.transfers == 0
+	s.mu.Unlock()
+
+	if last {
+		s.requestConnParams(idleConnParams)
+	}
+}
+
+// requestConnParams asks every connected central for new parameters.
+func (s *Server) requestConnParams(params bluetooth.ConnectionParams) {
+	s.mu.Lock()
+	devices := make(map[string]connParamsRequester, len(s.centrals))
+	for addr, d := range s.centrals {
+		if d != nil { // Unknown centrals can't be asked
+			devices[addr] = d
+		}
+	}
+	s.mu.Unlock()
+
+	for addr, d := range devices {
+		if err := d.RequestConnectionParams(params); err != nil {
+			slog.Warn("[BLE] Connection parameter request failed", "addr", addr, "err", err)
+		}
+	}
+}
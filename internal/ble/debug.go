@@ -0,0 +1,95 @@
+//go:build debug
+
+package ble
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// debugBuild reports whether the debug characteristic exists.
+const debugBuild = true
+
+// CharDebug (F0: Debug Events, Write) injects simulated conditions for app
+// QA. It only exists in builds with the debug tag.
+var CharDebug = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0xF0, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+
+// DebugEvent is written to the debug characteristic, e.g.
+// {"event": "low_battery", "percentage": 5}.
+type DebugEvent struct {
+	Event      string `json:"event"`                // low_battery, charging, disk_full, read_only, wifi_disconnect, device_swap
+	Percentage uint8  `json:"percentage,omitempty"` // For "low_battery", 5 if unset
+	Enabled    bool   `json:"enabled,omitempty"`    // For "charging", "disk_full" and "read_only"
+	Reason     string `json:"reason,omitempty"`     // For "wifi_disconnect"
+	Model      string `json:"model,omitempty"`      // For "device_swap", empty restores the default
+	Serial     string `json:"serial,omitempty"`     // For "device_swap", empty restores the default
+}
+
+// debugSimulator is implemented by hardware that can fake conditions (the
+// mock).
+type debugSimulator interface {
+	SimulateBatteryLevel(percentage uint8)
+	SimulateCharging(charging bool)
+	SimulateDiskFull(full bool)
+	SimulateReadOnlyStorage(readOnly bool)
+	SimulateWifiDisconnect(reason string)
+	SimulateDeviceSwap(model, serial string)
+}
+
+var errNoSimulator = errors.New("hardware can't simulate events")
+
+func (s *Server) debugCharacteristics() []bluetooth.CharacteristicConfig {
+	slog.Warn("[BLE] Debug build, event injection enabled")
+	return []bluetooth.CharacteristicConfig{
+		{
+			UUID:       CharDebug,
+			Flags:      bluetooth.CharacteristicWritePermission,
+			WriteEvent: s.guard("debug", s.handleDebugEvent),
+		},
+	}
+}
+
+func (s *Server) handleDebugEvent(client bluetooth.Connection, offset int, value []byte) {
+	var ev DebugEvent
+	if err := json.Unmarshal(value, &ev); err != nil {
+		slog.Error("[BLE] Invalid JSON in Debug", "err", err)
+		return
+	}
+	if err := s.injectDebugEvent(ev); err != nil {
+		slog.Error("[BLE] Debug event failed", "event", ev.Event, "err", err)
+		return
+	}
+	slog.Info("[BLE] Debug event injected", "event", ev.Event)
+}
+
+// injectDebugEvent fakes a condition through the hardware, whose state
+// events then notify the app as the real condition would.
+func (s *Server) injectDebugEvent(ev DebugEvent) error {
+	sim, ok := s.HW.(debugSimulator)
+	if !ok {
+		return errNoSimulator
+	}
+
+	switch ev.Event {
+	case "low_battery":
+		sim.SimulateBatteryLevel(cmp.Or(ev.Percentage, 5))
+	case "charging":
+		sim.SimulateCharging(ev.Enabled)
+	case "disk_full":
+		sim.SimulateDiskFull(ev.Enabled)
+	case "read_only":
+		sim.SimulateReadOnlyStorage(ev.Enabled)
+	case "wifi_disconnect":
+		sim.SimulateWifiDisconnect(cmp.Or(ev.Reason, "connection lost"))
+	case "device_swap":
+		sim.SimulateDeviceSwap(ev.Model, ev.Serial)
+	default:
+		return fmt.Errorf("unknown debug event %q", ev.Event)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !debug
+
+package ble
+
+import "tinygo.org/x/bluetooth"
+
+// debugBuild reports whether the debug characteristic exists.
+const debugBuild = false
+
+// debugCharacteristics is empty outside debug builds (see debug.go).
+func (s *Server) debugCharacteristics() []bluetooth.CharacteristicConfig {
+	return nil
+}
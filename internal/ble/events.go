@@ -0,0 +1,53 @@
+package ble
+
+import (
+	"time"
+
+	"blueowl-ble/internal/hardware"
+)
+
+// stateCoalesce is how long a state event waits for others of its kind, so
+// a burst (an error then a stop, say) is sent as one notification.
+const stateCoalesce = 50 * time.Millisecond
+
+// handleStateEvent pushes whatever a Controller state change affects. The
+// Controller may still hold its locks, so the status is read back on a
+// goroutine of its own.
+func (s *Server) handleStateEvent(ev hardware.StateEvent) {
+	s.mu.Lock()
+	queued := s.stateQueued[ev.Kind]
+	s.stateQueued[ev.Kind] = true
+	s.mu.Unlock()
+	if queued {
+		return
+	}
+
+	goSafe("state_"+ev.Kind, func() {
+		time.Sleep(stateCoalesce)
+		s.mu.Lock()
+		delete(s.stateQueued, ev.Kind)
+		s.mu.Unlock()
+
+		switch ev.Kind {
+		case hardware.StateRecorder:
+			s.notifyRecStatus()
+			s.refreshAdvertisement()
+			s.updateIMUStream()
+			s.updateDiskWatch()
+		case hardware.StateWifi:
+			s.notifyWifiStatus()
+		case hardware.StateDisk:
+			s.notifyDiskStatus()
+			s.updateStorageInfo()
+		case hardware.StateBattery:
+			s.notifyBattery()
+			s.refreshAdvertisement()
+		case hardware.StateSchedule:
+			s.updateSchedule()
+		case hardware.StateLocale:
+			s.updateLocale()
+		case hardware.StateDevice:
+			s.updateDeviceInfo()
+		}
+	}, nil)
+}
@@ -0,0 +1,221 @@
+package ble
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"blueowl-ble/internal/hardware"
+)
+
+// HTTPEnv, when set, is the address main serves Handler on, e.g. ":8080".
+// The endpoints are for debugging on a trusted network: they have no
+// authentication.
+const HTTPEnv = "BLUEOWL_HTTP"
+
+// WSEnv, when set alongside HTTPEnv, also serves the WebSocket bridge.
+// WSOriginsEnv lists, comma-separated, the web origins besides the
+// device's own allowed to open it, e.g. "http://localhost:3000".
+const (
+	WSEnv        = "BLUEOWL_WS"
+	WSOriginsEnv = "BLUEOWL_WS_ORIGINS"
+)
+
+// wsMaxInFlight is how many RPCs of one WebSocket run at once. Further
+// requests wait to be read until one finishes.
+const wsMaxInFlight = 4
+
+// sseKeepAlive is how often an idle event stream sends a comment, so
+// proxies and browsers don't drop it.
+const sseKeepAlive = 15 * time.Second
+
+// sseQueue is how many state changes an event stream holds while it's busy
+// sending. More are dropped; the next status sent covers them.
+const sseQueue = 16
+
+// Handler serves the debug HTTP endpoints, to watch the device from a
+// browser without a BLE app:
+//
+//	GET /events  Server-Sent Events carrying the status notification payloads
+//	GET /ws      the WebSocket bridge, if WebSocket is set
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", s.serveEvents)
+	if s.WebSocket {
+		mux.HandleFunc("GET /ws", s.serveWebSocket)
+	}
+	return mux
+}
+
+// WSEvent is a status event sent over the WebSocket bridge, named and
+// carrying the same payloads as the /events stream. Messages without
+// "event" are RPC responses.
+type WSEvent struct {
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
+
+// serveWebSocket bridges the BLE protocol over a WebSocket, for apps and
+// tools to be developed without a device in range: each message received
+// is an RPCRequest, answered with an RPCResponse as the RPC characteristic
+// would, and status events are pushed as WSEvent.
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrade(w, r, s.WSOrigins)
+	if err != nil {
+		slog.Warn("[BLE] WebSocket upgrade failed", "remote", r.RemoteAddr, "err", err)
+		return
+	}
+	defer conn.Close()
+
+	slog.Info("[BLE] WebSocket opened", "remote", r.RemoteAddr)
+	defer slog.Info("[BLE] WebSocket closed", "remote", r.RemoteAddr)
+
+	writeJSON := func(v any) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			slog.Error("[BLE] Failed to encode WebSocket message", "err", err)
+			return err
+		}
+		return conn.WriteText(data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	goSafe("websocket_events", func() {
+		s.streamEvents(ctx, func(event string, payload any) error {
+			return writeJSON(WSEvent{Event: event, Data: payload})
+		}, conn.Ping)
+		conn.Close() // Ends the read loop below when the events stop first
+	}, nil)
+
+	inFlight := make(chan struct{}, wsMaxInFlight)
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req RPCRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			writeJSON(RPCResponse{Error: &RPCError{Code: RPCParseError, Message: err.Error()}})
+			continue
+		}
+		inFlight <- struct{}{}
+		goSafe("websocket_rpc", func() {
+			defer func() { <-inFlight }()
+			writeJSON(s.dispatchRPC(req))
+		}, func() {
+			writeJSON(RPCResponse{ID: req.ID, Error: &RPCError{Code: RPCInternal, Message: "internal error"}})
+		})
+	}
+}
+
+// serveEvents streams the status of the device as Server-Sent Events, see
+// streamEvents. Payloads are always JSON.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	slog.Info("[BLE] HTTP event stream opened", "remote", r.RemoteAddr)
+	defer slog.Info("[BLE] HTTP event stream closed", "remote", r.RemoteAddr)
+
+	send := func(event string, payload any) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	keepAlive := func() error {
+		if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	s.streamEvents(r.Context(), send, keepAlive)
+}
+
+// streamEvents sends the status of the device through send: a "status"
+// event with everything first, then an event named after each state change
+// kind carrying the payload the BLE notification would, and "operation"
+// events. keepAlive is called when nothing was sent for sseKeepAlive. It
+// returns when ctx is done, the server stops, or either function fails.
+func (s *Server) streamEvents(ctx context.Context, send func(event string, payload any) error, keepAlive func() error) {
+	// Subscribers may be called with the Controller's locks held, so they
+	// only queue what happened for this goroutine to read back
+	states := make(chan string, sseQueue)
+	ops := make(chan hardware.OperationEvent, sseQueue)
+	cancelState := s.HW.SubscribeState(func(ev hardware.StateEvent) {
+		select {
+		case states <- ev.Kind:
+		default:
+		}
+	})
+	defer cancelState()
+	queueOp := func(ev hardware.OperationEvent) {
+		select {
+		case ops <- ev:
+		default:
+		}
+	}
+	defer s.HW.SubscribeOperations(queueOp)()
+	defer s.ops.Subscribe(queueOp)()
+
+	if status, err := s.allStatus(); err == nil {
+		if err := send("status", status); err != nil {
+			return
+		}
+	}
+	idle := time.NewTicker(sseKeepAlive)
+	defer idle.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		case kind := <-states:
+			// Unreadable status is skipped; the next change may be readable
+			if payload, perr := s.statePayload(kind); perr == nil {
+				err = send(kind, payload)
+			}
+		case ev := <-ops:
+			err = send("operation", ev)
+		case <-idle.C:
+			err = keepAlive()
+		}
+		if err != nil {
+			return // The client went away
+		}
+	}
+}
+
+// statePayload reads back what a state change of a kind affects, as its
+// BLE notification carries it. Kinds without a status payload only report
+// the change.
+func (s *Server) statePayload(kind string) (any, error) {
+	switch kind {
+	case hardware.StateRecorder:
+		return s.recStatusPayload()
+	case hardware.StateWifi:
+		return s.wifiStatusPayload()
+	case hardware.StateDisk:
+		return s.diskStatusPayload()
+	case hardware.StateBattery:
+		return callWithTimeout(s.CallTimeout, s.HW.GetBatteryStatus)
+	}
+	return hardware.StateEvent{Kind: kind, Unix: time.Now().Unix()}, nil
+}
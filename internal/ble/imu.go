@@ -0,0 +1,82 @@
+package ble
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"math"
+	"time"
+
+	"blueowl-ble/internal/hardware"
+)
+
+// imuNotifyInterval throttles the IMU stream to 10 samples a second, which
+// shows the sensor is alive without crowding out the other notifications.
+const imuNotifyInterval = 100 * time.Millisecond
+
+// imuSampleSize is a packed IMUSample: x, y and z as float32, then the
+// timestamp in ms, all little-endian.
+const imuSampleSize = 16
+
+func packIMUSample(sample hardware.IMUSample) []byte {
+	buf := make([]byte, 0, imuSampleSize)
+	buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(sample.X))
+	buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(sample.Y))
+	buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(sample.Z))
+	return binary.LittleEndian.AppendUint32(buf, sample.Millis)
+}
+
+// updateIMUStream streams the IMU while a central is connected, and stops
+// the stream once none is. The stream also ends by itself when the
+// recording stops, so this runs again on every recorder state change to
+// pick up the next recording.
+func (s *Server) updateIMUStream() {
+	connected := s.hasSubscribers()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !connected {
+		if s.imuCancel != nil {
+			s.imuCancel()
+			s.imuCancel = nil
+		}
+		return
+	}
+	if s.imuCancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.imuCancel = cancel
+	s.imuGen++
+	gen := s.imuGen
+	goSafe("imu_stream", func() {
+		defer func() {
+			cancel()
+			s.mu.Lock()
+			if s.imuGen == gen {
+				s.imuCancel = nil
+			}
+			s.mu.Unlock()
+		}()
+		s.streamIMU(ctx)
+	}, nil)
+}
+
+// streamIMU notifies the Controller's samples, at most one per
+// imuNotifyInterval, until the stream ends.
+func (s *Server) streamIMU(ctx context.Context) {
+	var sent time.Time
+	n := 0
+	for sample := range s.HW.StreamIMU(ctx) {
+		if time.Since(sent) < imuNotifyInterval {
+			continue
+		}
+		sent = time.Now()
+		s.write(&s.imuHandle, packIMUSample(sample))
+		n++
+	}
+	if n > 0 {
+		slog.Info("[BLE] IMU stream ended", "samples", n)
+	}
+}
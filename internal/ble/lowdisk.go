@@ -0,0 +1,89 @@
+package ble
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// diskWatchInterval is how often free space is checked while recording.
+// Even at the highest bitrate a few seconds use only a few MB.
+const diskWatchInterval = 5 * time.Second
+
+// lowDiskAction is the CmdResult action of the alert sent when a recording
+// is stopped for lack of space.
+const lowDiskAction = "low_disk_stop"
+
+// updateDiskWatch watches free space while recording, and stops watching
+// once the recording stops. It runs on every recorder state change.
+func (s *Server) updateDiskWatch() {
+	info, err := callWithTimeout(s.CallTimeout, s.HW.GetRecorderInfo)
+	recording := err == nil && info.FilenameTag != ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !recording {
+		if s.diskWatchCancel != nil {
+			s.diskWatchCancel()
+			s.diskWatchCancel = nil
+		}
+		return
+	}
+	if s.diskWatchCancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.diskWatchCancel = cancel
+	s.diskWatchGen++
+	gen := s.diskWatchGen
+	goSafe("disk_watch", func() {
+		defer func() {
+			cancel()
+			s.mu.Lock()
+			if s.diskWatchGen == gen {
+				s.diskWatchCancel = nil
+			}
+			s.mu.Unlock()
+		}()
+		s.watchDisk(ctx)
+	}, nil)
+}
+
+// watchDisk stops the recording once free space falls below the
+// recorder's MinFreeMB, alerting the app on the Command Result
+// characteristic, and returns then or when ctx is done.
+func (s *Server) watchDisk(ctx context.Context) {
+	ticker := time.NewTicker(diskWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := callWithTimeout(s.CallTimeout, s.HW.GetRecorderInfo)
+		if err != nil || info.MinFreeMB == 0 {
+			continue // Re-read each time, the threshold may change mid-recording
+		}
+		disk, err := callWithTimeout(s.CallTimeout, s.HW.GetDiskStatus)
+		if err != nil || disk.FreeMB >= info.MinFreeMB {
+			continue
+		}
+
+		slog.Warn("[BLE] Free space low, stopping recording", "tag", info.FilenameTag, "free_mb", disk.FreeMB, "min_free_mb", info.MinFreeMB)
+		result := CmdResult{
+			Action: lowDiskAction,
+			Error:  fmt.Sprintf("free space %d MB is below %d MB", disk.FreeMB, info.MinFreeMB),
+		}
+		if err := s.call(s.HW.StopRecorder); err != nil {
+			slog.Error("[BLE] Failed to stop recorder", "err", err)
+			result.Error += ", stopping failed: " + err.Error()
+		}
+		s.writeCmdResult(result)
+		return
+	}
+}
@@ -0,0 +1,62 @@
+package ble
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// DefaultMTU is assumed until the client reports its negotiated ATT MTU.
+// It matches what iOS and most Android centrals negotiate with BlueZ.
+const DefaultMTU = 185
+
+const (
+	attHeaderSize  = 3   // Opcode + handle
+	minPayloadSize = 20  // ATT_MTU 23, the spec minimum
+	maxPayloadSize = 512 // Longest attribute value allowed
+)
+
+// payloadSize returns the usable bytes per notification/indication for an
+// ATT MTU.
+func payloadSize(mtu uint16) int {
+	return min(max(int(mtu)-attHeaderSize, minPayloadSize), maxPayloadSize)
+}
+
+// Browser messages are framed for reassembly: a u16 little-endian length
+// followed by the message, split into fragments of one notification each.
+// A client reads the length from the first fragment of a message and
+// collects fragments until it has that many bytes.
+const (
+	frameHeaderSize = 2
+	maxFrameSize    = 1<<16 - 1
+
+	// fragmentGap spaces out the fragments of one message
+	fragmentGap = 20 * time.Millisecond
+)
+
+// errFrameTooLarge is returned for a message the length header can't describe.
+var errFrameTooLarge = errors.New("message too large for one frame")
+
+// writeChunked sends one length-prefixed message, fragmented to fit the
+// active connection's MTU.
+func (s *Server) writeChunked(h charWriter, data []byte) error {
+	if len(data) > maxFrameSize {
+		slog.Error("[BLE] Dropping oversized message", "bytes", len(data))
+		return errFrameTooLarge
+	}
+
+	msg := binary.LittleEndian.AppendUint16(make([]byte, 0, frameHeaderSize+len(data)), uint16(len(data)))
+	msg = append(msg, data...)
+
+	chunk := s.chunkSize()
+	for off := 0; off < len(msg); off += chunk {
+		if off > 0 {
+			time.Sleep(fragmentGap)
+		}
+		if err := s.write(h, msg[off:min(off+chunk, len(msg))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
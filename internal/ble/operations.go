@@ -0,0 +1,74 @@
+package ble
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"blueowl-ble/internal/hardware"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// OpBrowserStream is the operation type of a browser response being sent.
+const OpBrowserStream = "browser_stream"
+
+// serverOpIDBase keeps the server's operation IDs apart from the Controller's.
+const serverOpIDBase = 1 << 31
+
+// activeOperations lists the Controller's running operations, then the
+// server's own.
+func (s *Server) activeOperations() ([]hardware.Operation, error) {
+	ops, err := callWithTimeout(s.CallTimeout, s.HW.GetActiveOperations)
+	if err != nil {
+		return nil, err
+	}
+	return append(ops, s.ops.List()...), nil
+}
+
+// OpEventsFilter is written to the Operation Events characteristic to
+// receive only events of the listed operation types, e.g.
+// {"ops": ["wifi_scan", "manifest"]}. An empty list receives every event.
+type OpEventsFilter struct {
+	Ops []string `json:"ops"`
+}
+
+func (s *Server) handleOpEventsFilter(client bluetooth.Connection, offset int, value []byte) {
+	var filter OpEventsFilter
+	if err := json.Unmarshal(value, &filter); err != nil {
+		slog.Error("[BLE] Invalid JSON in OpEvents", "err", err)
+		return
+	}
+
+	var ops map[string]bool
+	if len(filter.Ops) > 0 {
+		ops = make(map[string]bool, len(filter.Ops))
+		for _, op := range filter.Ops {
+			ops[op] = true
+		}
+	}
+
+	s.mu.Lock()
+	s.client(client).opFilter = ops
+	s.mu.Unlock()
+	slog.Info("[BLE] Operation events filtered", "client", client, "ops", filter.Ops)
+}
+
+// wantsOperation reports whether the active client's filter lets events of
+// an operation type through.
+func (s *Server) wantsOperation(op string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clients[s.activeClient]
+	return !ok || c.opFilter == nil || c.opFilter[op]
+}
+
+// notifyOperation sends an operation event, from the Controller or the
+// server, on the Operation Events characteristic.
+func (s *Server) notifyOperation(ev hardware.OperationEvent) {
+	if !s.hasSubscribers() || !s.wantsOperation(ev.Op) {
+		return
+	}
+	if data, err := json.Marshal(ev); err == nil {
+		s.write(&s.opEventsHandle, data)
+	}
+}
@@ -0,0 +1,100 @@
+package ble
+
+import "encoding/json"
+
+// replaySize is how many notifications are kept per characteristic.
+const replaySize = 32
+
+// Replayable status characteristics, as named in "replay" requests
+const (
+	replayRecStatus  = "rec_status"
+	replayWifiStatus = "wifi_status"
+	replayDiskStatus = "disk_status"
+)
+
+// notifyLog numbers the notifications of one characteristic and keeps the
+// most recent ones so a reconnecting client can ask for what it missed.
+type notifyLog struct {
+	seq    uint32
+	recent []sequenced
+}
+
+// sequenced is a status payload carrying its notification sequence number.
+type sequenced interface {
+	withSeq(seq uint32) sequenced
+	sequence() uint32
+}
+
+// record assigns the next sequence number to p and keeps it.
+// Caller must hold s.mu.
+func (l *notifyLog) record(p sequenced) sequenced {
+	l.seq++
+	p = p.withSeq(l.seq)
+	if len(l.recent) == replaySize {
+		l.recent = append(l.recent[:0], l.recent[1:]...)
+	}
+	l.recent = append(l.recent, p)
+	return p
+}
+
+// since returns the kept payloads after seq, oldest first, and whether some
+// were already dropped from the ring. Caller must hold s.mu.
+func (l *notifyLog) since(seq uint32) (out []sequenced, gap bool) {
+	for _, p := range l.recent {
+		if p.sequence() > seq {
+			out = append(out, p)
+		}
+	}
+	if len(out) > 0 && out[0].sequence() > seq+1 {
+		gap = true
+	}
+	return out, gap
+}
+
+func (p RecStatusPayload) withSeq(seq uint32) sequenced  { p.Seq = seq; return p }
+func (p RecStatusPayload) sequence() uint32              { return p.Seq }
+func (p WifiStatusPayload) withSeq(seq uint32) sequenced { p.Seq = seq; return p }
+func (p WifiStatusPayload) sequence() uint32             { return p.Seq }
+func (p DiskStatusPayload) withSeq(seq uint32) sequenced { p.Seq = seq; return p }
+func (p DiskStatusPayload) sequence() uint32             { return p.Seq }
+
+// recordNotify numbers a payload for the named characteristic.
+func (s *Server) recordNotify(name string, p sequenced) sequenced {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replay[name].record(p)
+}
+
+// ReplayGap is sent first when the client asked for notifications that were
+// already dropped; it should re-read the characteristic instead.
+type ReplayGap struct {
+	Gap       bool   `json:"gap"`
+	OldestSeq uint32 `json:"oldest_seq"`
+}
+
+// replayFrames returns the notifications of a characteristic after sinceSeq.
+func (s *Server) replayFrames(name string, sinceSeq uint32) [][]byte {
+	s.mu.Lock()
+	log, ok := s.replay[name]
+	var missed []sequenced
+	var gap bool
+	if ok {
+		missed, gap = log.since(sinceSeq)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return [][]byte{[]byte(`{"error": "unknown_characteristic"}`)}
+	}
+
+	var frames [][]byte
+	if gap {
+		data, _ := json.Marshal(ReplayGap{Gap: true, OldestSeq: missed[0].sequence()})
+		frames = append(frames, data)
+	}
+	for _, p := range missed {
+		data, _ := json.Marshal(p)
+		frames = append(frames, data)
+	}
+	return frames
+}
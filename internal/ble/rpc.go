@@ -0,0 +1,199 @@
+package ble
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"blueowl-ble/internal/hardware"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// RPCRequest is written to the RPC characteristic. ID is echoed in the
+// response so the client can match them up.
+type RPCRequest struct {
+	ID     uint32          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// RPCResponse is indicated on the RPC characteristic, with either a result
+// or an error. Like a browser message it is framed for reassembly: a u16
+// little-endian length, then the JSON, split into fragments of one
+// indication each. Responses to concurrent requests never interleave, but
+// may come back in any order.
+type RPCResponse struct {
+	ID     uint32    `json:"id"`
+	Result any       `json:"result,omitempty"`
+	Error  *RPCError `json:"error,omitempty"`
+}
+
+// RPCError codes
+const (
+	RPCParseError    = "parse_error"
+	RPCUnknownMethod = "unknown_method"
+	RPCInvalidParams = "invalid_params"
+	RPCTimeout       = "timeout"
+	RPCBusy          = "busy" // The tag is being recorded into
+	RPCNotFound      = "not_found"
+	RPCQuotaExceeded = "quota_exceeded"
+	RPCNoSpace       = "insufficient_space"
+	RPCReadOnly      = "read_only" // The storage can't be written
+	RPCInternal      = "internal"
+	RPCFailed        = "failed" // Any other Controller error
+)
+
+type RPCError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcMethod runs one RPC method. params is the raw "params" of the request.
+type rpcMethod func(s *Server, ctx context.Context, params json.RawMessage) (any, error)
+
+// rpcMethodSpec is an entry of the rpcMethods table.
+type rpcMethodSpec struct {
+	call rpcMethod
+
+	// timeout overrides s.CallTimeout for slow methods, 0 waits for the
+	// method however long it takes
+	timeout func(s *Server) time.Duration
+}
+
+// rpcValidator is implemented by params with required fields.
+type rpcValidator interface {
+	validate() error
+}
+
+// rpcHandler adapts a method taking decoded params of type P. Unknown
+// fields are rejected so a misspelt param doesn't silently use its zero value.
+func rpcHandler[P any](fn func(s *Server, ctx context.Context, p P) (any, error)) rpcMethod {
+	return func(s *Server, ctx context.Context, raw json.RawMessage) (any, error) {
+		var p P
+		if len(raw) > 0 && !bytes.Equal(raw, []byte("null")) {
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&p); err != nil {
+				return nil, errInvalidParams{err}
+			}
+		}
+		if v, ok := any(p).(rpcValidator); ok {
+			if err := v.validate(); err != nil {
+				return nil, errInvalidParams{err}
+			}
+		}
+		return fn(s, ctx, p)
+	}
+}
+
+// rpcNoParams adapts a Controller getter without params.
+func rpcNoParams[T any](fn func(hw hardware.Controller) (T, error)) rpcMethod {
+	return func(s *Server, _ context.Context, _ json.RawMessage) (any, error) {
+		return fn(s.HW)
+	}
+}
+
+type errInvalidParams struct{ err error }
+
+func (e errInvalidParams) Error() string { return "invalid params: " + e.err.Error() }
+
+// rpcErrorCode maps an error to the code the client acts on.
+func rpcErrorCode(err error) string {
+	var invalid errInvalidParams
+	switch {
+	case errors.As(err, &invalid), errors.Is(err, hardware.ErrInvalidLocale),
+		errors.Is(err, hardware.ErrInvalidSort), errors.Is(err, hardware.ErrInvalidTag),
+		errors.Is(err, hardware.ErrInvalidConfig),
+		errors.Is(err, hardware.ErrInvalidTime), errors.Is(err, ErrInvalidStatusInterval):
+		return RPCInvalidParams
+	case errors.Is(err, ErrControllerTimeout),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, hardware.ErrWifiTimeout):
+		return RPCTimeout
+	case errors.Is(err, hardware.ErrTagRecording),
+		errors.Is(err, hardware.ErrRecordingInProgress),
+		errors.Is(err, hardware.ErrAlreadyRecording):
+		return RPCBusy
+	case errors.Is(err, hardware.ErrNotInTrash), errors.Is(err, hardware.ErrRecordingNotFound),
+		errors.Is(err, os.ErrNotExist):
+		return RPCNotFound
+	case errors.Is(err, hardware.ErrTagQuotaExceeded):
+		return RPCQuotaExceeded
+	case errors.Is(err, hardware.ErrInsufficientSpace):
+		return RPCNoSpace
+	case errors.Is(err, hardware.ErrStorageReadOnly):
+		return RPCReadOnly
+	}
+	return RPCFailed
+}
+
+func (s *Server) handleRPC(client bluetooth.Connection, offset int, value []byte) {
+	var req RPCRequest
+	if err := json.Unmarshal(value, &req); err != nil {
+		slog.Error("[BLE] Invalid JSON in RPC", "err", err)
+		s.writeRPC(RPCResponse{Error: &RPCError{Code: RPCParseError, Message: err.Error()}})
+		return
+	}
+
+	goSafe("rpc", func() {
+		s.writeRPC(s.dispatchRPC(req))
+	}, func() {
+		s.writeRPC(RPCResponse{ID: req.ID, Error: &RPCError{Code: RPCInternal, Message: "internal error"}})
+	})
+}
+
+// dispatchRPC runs a request against the method table.
+func (s *Server) dispatchRPC(req RPCRequest) RPCResponse {
+	spec, ok := rpcMethods[req.Method]
+	if !ok {
+		slog.Warn("[BLE] Unknown RPC method", "method", req.Method)
+		return RPCResponse{ID: req.ID, Error: &RPCError{Code: RPCUnknownMethod, Message: req.Method}}
+	}
+
+	timeout := s.CallTimeout
+	if spec.timeout != nil {
+		timeout = spec.timeout(s)
+	}
+
+	var result any
+	var err error
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		result, err = callWithTimeout(timeout, func() (any, error) {
+			return spec.call(s, ctx, req.Params)
+		})
+	} else {
+		result, err = spec.call(s, context.Background(), req.Params)
+	}
+	if err != nil {
+		slog.Warn("[BLE] RPC failed", "method", req.Method, "err", err)
+		return RPCResponse{ID: req.ID, Error: &RPCError{Code: rpcErrorCode(err), Message: err.Error()}}
+	}
+	return RPCResponse{ID: req.ID, Result: result}
+}
+
+// writeRPC sends a response, fragmented to fit the MTU.
+func (s *Server) writeRPC(resp RPCResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("[BLE] Failed to encode RPC response", "err", err)
+		return
+	}
+	s.rpcMu.Lock()
+	defer s.rpcMu.Unlock()
+	err = s.writeChunked(&s.rpcHandle, data)
+	if errors.Is(err, errFrameTooLarge) {
+		// Still answer, or the client waits for nothing
+		data, _ = json.Marshal(RPCResponse{ID: resp.ID, Error: &RPCError{Code: RPCInternal, Message: err.Error()}})
+		err = s.writeChunked(&s.rpcHandle, data)
+	}
+	if err != nil {
+		slog.Error("[BLE] Failed to send RPC response", "id", resp.ID, "err", err)
+	}
+}
@@ -0,0 +1,277 @@
+package ble
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"blueowl-ble/internal/hardware"
+)
+
+// Params of the RPC methods. Those implementing rpcValidator are checked
+// before the call.
+type (
+	rpcTagParams struct {
+		Tag string `json:"tag"`
+	}
+	rpcIndexParams struct {
+		Index uint32 `json:"index"`
+	}
+	rpcFileParams struct {
+		Tag   string          `json:"tag"`
+		Index uint32          `json:"index"`
+		Sort  hardware.SortBy `json:"sort,omitempty"` // For get_recording_details
+	}
+	rpcIDParams struct {
+		ID uint32 `json:"id"`
+	}
+	rpcRecordingParams struct {
+		Tag string `json:"tag"`
+		ID  uint32 `json:"id"`
+	}
+	rpcSinceParams struct {
+		Since int64 `json:"since"`
+	}
+	rpcEnabledParams struct {
+		Enabled bool `json:"enabled"`
+	}
+	rpcReasonParams struct {
+		Reason string `json:"reason"`
+	}
+	rpcStartParams struct {
+		hardware.StartOptions
+	}
+	rpcPreallocateParams struct {
+		EstimatedMB uint64 `json:"estimated_mb"`
+	}
+	rpcLocaleParams struct {
+		Locale string `json:"locale"`
+	}
+	rpcIntervalParams struct {
+		Secs uint32 `json:"secs"`
+	}
+	rpcWifiParams struct {
+		SSID     string `json:"ssid"`
+		Password string `json:"password"`
+	}
+)
+
+var errMissingTag = errors.New("tag is required")
+
+func (p rpcTagParams) validate() error {
+	if p.Tag == "" {
+		return errMissingTag
+	}
+	return nil
+}
+
+func (p rpcRecordingParams) validate() error {
+	if p.Tag == "" {
+		return errMissingTag
+	}
+	return nil
+}
+
+func (p rpcFileParams) validate() error {
+	if p.Tag == "" {
+		return errMissingTag
+	}
+	return nil
+}
+
+func (p rpcPreallocateParams) validate() error {
+	if p.EstimatedMB == 0 {
+		return errors.New("estimated_mb must be positive")
+	}
+	return nil
+}
+
+func (p rpcStartParams) validate() error {
+	if p.Tag == "" {
+		return errMissingTag
+	}
+	return nil
+}
+
+func (p rpcIDParams) validate() error {
+	if p.ID == 0 {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+func (p rpcReasonParams) validate() error {
+	if p.Reason == "" {
+		return errors.New("reason is required")
+	}
+	return nil
+}
+
+func (p rpcWifiParams) validate() error {
+	if p.SSID == "" {
+		return errors.New("ssid is required")
+	}
+	return nil
+}
+
+// rpcMethods is the dispatch table of the RPC characteristic. A new method
+// is one entry: its handler and, when needed, a longer timeout, or none for
+// methods that remove files.
+var rpcMethods = map[string]rpcMethodSpec{
+	// Status
+	// get_status is larger than an MTU, fragmented by writeRPC
+	"get_status": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		return s.allStatus()
+	})},
+	"get_battery_status": {call: rpcNoParams(hardware.Controller.GetBatteryStatus)},
+	"get_disk_status":    {call: rpcNoParams(hardware.Controller.GetDiskStatus)},
+	"get_storage_info":   {call: rpcNoParams(hardware.Controller.GetStorageInfo)},
+	"get_active_operations": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		return s.activeOperations()
+	})},
+	"get_runtime_stats": {call: rpcNoParams(hardware.Controller.GetRuntimeStats)},
+	"benchmark_storage": {timeout: benchmarkTimeout, call: rpcNoParams(hardware.Controller.BenchmarkStorage)},
+
+	// Recorder
+	"get_recorder_info":       {call: rpcNoParams(hardware.Controller.GetRecorderInfo)},
+	"get_recorder_live_stats": {call: rpcNoParams(hardware.Controller.GetRecorderLiveStats)},
+	"get_session_id":          {call: rpcNoParams(hardware.Controller.GetCurrentSessionID)},
+	"start_recorder": {call: rpcHandler(func(s *Server, ctx context.Context, p rpcStartParams) (any, error) {
+		return nil, s.HW.StartRecorderWithOptions(ctx, p.StartOptions)
+	})},
+	"preallocate_recording": {call: rpcHandler(func(s *Server, _ context.Context, p rpcPreallocateParams) (any, error) {
+		return nil, s.HW.PreallocateRecording(p.EstimatedMB)
+	})},
+	"stop_recorder": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		return nil, s.HW.StopRecorder()
+	})},
+	"pause_recorder": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		return nil, s.HW.PauseRecorder()
+	})},
+	"resume_recorder": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		return nil, s.HW.ResumeRecorder()
+	})},
+	"setup_recorder": {call: rpcHandler(func(s *Server, _ context.Context, p hardware.RecorderParameters) (any, error) {
+		return nil, s.HW.SetupRecorder(p)
+	})},
+	"set_auto_restart": {call: rpcHandler(func(s *Server, _ context.Context, p rpcEnabledParams) (any, error) {
+		return nil, s.HW.SetAutoRestart(p.Enabled)
+	})},
+	"trigger_recording": {call: rpcHandler(func(s *Server, _ context.Context, p rpcReasonParams) (any, error) {
+		return nil, s.HW.TriggerRecording(p.Reason)
+	})},
+	"get_recorder_events": {call: rpcHandler(func(s *Server, _ context.Context, p rpcSinceParams) (any, error) {
+		return s.HW.GetRecorderEvents(p.Since)
+	})},
+	"get_schedule": {call: rpcNoParams(hardware.Controller.GetSchedule)},
+	"set_schedule": {call: rpcHandler(func(s *Server, _ context.Context, p []hardware.RecordingWindow) (any, error) {
+		return nil, s.HW.SetSchedule(p)
+	})},
+	"get_locale": {call: rpcNoParams(hardware.Controller.GetLocale)},
+	"set_locale": {call: rpcHandler(func(s *Server, _ context.Context, p rpcLocaleParams) (any, error) {
+		return nil, s.HW.SetLocale(p.Locale)
+	})},
+	"get_status_interval": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		return rpcIntervalParams{Secs: uint32(s.statusInterval() / time.Second)}, nil
+	})},
+	"set_status_interval": {call: rpcHandler(func(s *Server, _ context.Context, p rpcIntervalParams) (any, error) {
+		return nil, s.SetStatusInterval(time.Duration(p.Secs) * time.Second)
+	})},
+	"get_system_time": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		now, err := s.HW.GetSystemTime()
+		if err != nil {
+			return nil, err
+		}
+		return TimeSync{Epoch: now.Unix()}, nil
+	})},
+	"set_system_time": {call: rpcHandler(func(s *Server, _ context.Context, p TimeSync) (any, error) {
+		return nil, s.HW.SetSystemTime(time.Unix(p.Epoch, 0))
+	})},
+
+	// Browser
+	"list_tags": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		count, err := s.HW.GetNumOfTags()
+		if err != nil {
+			return nil, err
+		}
+		tags := make([]*hardware.TagInfo, 0, count)
+		for i := range count {
+			tag, err := s.HW.GetTagInfoByIndex(i)
+			if err != nil {
+				return nil, err
+			}
+			tags = append(tags, tag)
+		}
+		return tags, nil
+	})},
+	"get_num_of_tags": {call: rpcNoParams(hardware.Controller.GetNumOfTags)},
+	"get_tag_info": {call: rpcHandler(func(s *Server, _ context.Context, p rpcIndexParams) (any, error) {
+		return s.HW.GetTagInfoByIndex(p.Index)
+	})},
+	"get_recording_details": {call: rpcHandler(func(s *Server, _ context.Context, p rpcFileParams) (any, error) {
+		return s.HW.GetRecordingDetailsSorted(p.Tag, p.Index, p.Sort)
+	})},
+	"checksum_recording": {timeout: checksumTimeout, call: rpcHandler(func(s *Server, _ context.Context, p rpcFileParams) (any, error) {
+		return s.HW.ChecksumRecording(p.Tag, p.Index)
+	})},
+	"generate_thumbnail": {timeout: thumbnailTimeout, call: rpcHandler(func(s *Server, _ context.Context, p rpcFileParams) (any, error) {
+		return nil, s.HW.GenerateThumbnail(p.Tag, p.Index)
+	})},
+	"get_tag_disk_usage": {call: rpcHandler(func(s *Server, _ context.Context, p rpcTagParams) (any, error) {
+		return s.HW.GetTagDiskUsage(p.Tag)
+	})},
+	"get_tag_manifest": {timeout: manifestTimeout, call: rpcHandler(func(s *Server, _ context.Context, p rpcTagParams) (any, error) {
+		return s.HW.GetTagManifest(p.Tag)
+	})},
+	"repair_tag": {timeout: noTimeout, call: rpcHandler(func(s *Server, _ context.Context, p rpcTagParams) (any, error) {
+		return s.HW.RepairTag(p.Tag)
+	})},
+	"delete_tag": {timeout: noTimeout, call: rpcHandler(func(s *Server, _ context.Context, p rpcTagParams) (any, error) {
+		freed, err := s.HW.DeleteTag(p.Tag)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]uint64{"freed_bytes": freed}, nil
+	})},
+	"delete_recording": {timeout: noTimeout, call: rpcHandler(func(s *Server, _ context.Context, p rpcRecordingParams) (any, error) {
+		return nil, s.HW.DeleteRecording(p.Tag, p.ID)
+	})},
+	"restore_recording": {timeout: noTimeout, call: rpcHandler(func(s *Server, _ context.Context, p rpcIDParams) (any, error) {
+		return nil, s.HW.RestoreRecording(p.ID)
+	})},
+	"empty_trash": {timeout: noTimeout, call: rpcNoParams(hardware.Controller.EmptyTrash)},
+
+	// Config
+	"export_config": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		data, err := s.HW.ExportConfig()
+		return json.RawMessage(data), err
+	})},
+	"import_config": {call: rpcHandler(func(s *Server, _ context.Context, p json.RawMessage) (any, error) {
+		return nil, s.HW.ImportConfig(p)
+	})},
+
+	// Wifi
+	"setup_wifi": {call: rpcHandler(func(s *Server, _ context.Context, p rpcWifiParams) (any, error) {
+		return nil, s.HW.SetupWifi(p.SSID, p.Password)
+	})},
+	"connect_wifi": {timeout: wifiTimeout, call: rpcHandler(func(s *Server, ctx context.Context, _ struct{}) (any, error) {
+		return nil, s.HW.ConnectToWifi(ctx)
+	})},
+	"get_wifi_status": {call: rpcHandler(func(s *Server, _ context.Context, _ struct{}) (any, error) {
+		return s.wifiStatusPayload()
+	})},
+}
+
+// noTimeout runs a method without the watchdog (see callWithTimeout).
+func noTimeout(*Server) time.Duration { return 0 }
+
+func wifiTimeout(s *Server) time.Duration { return s.WifiTimeout }
+
+func benchmarkTimeout(*Server) time.Duration { return storageBenchmarkTimeout }
+
+func thumbnailTimeout(*Server) time.Duration { return thumbnailGenerateTimeout }
+
+func checksumTimeout(*Server) time.Duration { return recordingChecksumTimeout }
+
+func manifestTimeout(*Server) time.Duration { return tagManifestTimeout }
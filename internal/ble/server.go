@@ -2,13 +2,23 @@ package ble
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
 	"log/slog"
 	"os"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"blueowl-ble/internal/hardware"
+	"blueowl-ble/internal/version"
 
 	"tinygo.org/x/bluetooth"
 )
@@ -18,11 +28,15 @@ var (
 	// Standard Services
 	ServiceBattery   = bluetooth.ServiceUUIDBattery
 	CharBatteryLevel = bluetooth.CharacteristicUUIDBatteryLevel
+	CharBatteryPower = bluetooth.CharacteristicUUIDBatteryPowerState
 
 	ServiceDeviceInfo = bluetooth.ServiceUUIDDeviceInformation
 	CharManufacturer  = bluetooth.CharacteristicUUIDManufacturerNameString
 	CharModel         = bluetooth.CharacteristicUUIDModelNumberString
 	CharSerialNumber  = bluetooth.CharacteristicUUIDSerialNumberString
+	CharFirmware      = bluetooth.CharacteristicUUIDFirmwareRevisionString
+	CharHardware      = bluetooth.CharacteristicUUIDHardwareRevisionString
+	CharSoftware      = bluetooth.CharacteristicUUIDSoftwareRevisionString
 
 	// Custom Owl Service (Base: A0B4xxxx-926D-4D61-98DF-8C5C62EE53B3)
 	ServiceOwlUUID = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x00, 0x92, 0x6D, 0x4D, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
@@ -40,30 +54,211 @@ var (
 	CharWifiStatus = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x05, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
 	// 06: Disk Status (Read/Notify) - NEW
 	CharDiskStatus = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x06, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 07: Protocol Negotiation (Read/Write)
+	CharProtocol = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x07, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 08: Recording Schedule (Read), set through Recorder Control
+	CharSchedule = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x08, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 09: Battery Time Remaining (Read/Notify), minutes as u16 LE
+	CharBatteryTime = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x09, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 0A: RPC (Write / Indicate), request/response on one characteristic.
+	// Responses are framed as browser messages are.
+	CharRPC = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x0A, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 0B: Locale (Read/Write), a language tag such as "en-US" as UTF-8
+	CharLocale = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x0B, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 0C: Command Result (Notify), the outcome of each Recorder Control write,
+	// framed as browser messages are
+	CharCmdResult = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x0C, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 0D: Operation Events (Write / Notify), progress of background operations
+	// as JSON. Writing an OpEventsFilter picks which operation types are sent.
+	CharOpEvents = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x0D, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 0E: Config Import (Write / Notify), a DeviceConfig framed as browser
+	// messages are, answered with a CmdResult framed the same way. Export with
+	// the "config" browser request.
+	CharConfigImport = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x0E, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 0F: IMU Stream (Read / Notify), accelerometer samples while recording,
+	// packed as x, y, z float32 (g) and a uint32 ms timestamp, little-endian
+	CharImuStream = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x0F, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 10: Time Sync (Write), sets the device clock from a Unix epoch, as 8
+	// bytes little-endian or a TimeSync
+	CharTimeSync = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x10, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
+	// 11: Storage Info (Read / Notify), the recordings filesystem as a
+	// hardware.StorageInfo, updated with the disk status
+	CharStorageInfo = bluetooth.NewUUID([16]byte{0xA0, 0xB4, 0x00, 0x11, 0x92, 0x6D, 0x4d, 0x61, 0x98, 0xDF, 0x8C, 0x5C, 0x62, 0xEE, 0x53, 0xB3})
 )
 
 type Server struct {
 	Adapter *bluetooth.Adapter
 	HW      hardware.Controller
 
+	// Format used for status notifications (JSON unless set otherwise)
+	Format PayloadFormat
+
+	// CallTimeout bounds each Controller call made from a BLE handler
+	CallTimeout time.Duration
+
+	// WifiTimeout bounds connecting to a network after Wifi Setup
+	WifiTimeout time.Duration
+
+	// StreamTimeout bounds sending one browser response, 0 disables it.
+	// A stream cut short ends with {"timeout": true} instead of {}, and
+	// one cancelled by a newer request with {"cancelled": true}.
+	StreamTimeout time.Duration
+
+	// StreamPacing spaces out the frames of a browser response, 0 sends
+	// them back to back. BlueZ doesn't report when a central has
+	// acknowledged an indication, so there is no flow control to wait on:
+	// too short and a slow central drops frames, too long and a fast one
+	// waits for nothing.
+	StreamPacing time.Duration
+
+	// WebSocket serves the WebSocket bridge from Handler, which speaks the
+	// RPC protocol over TCP. Like the other HTTP endpoints it has no
+	// authentication.
+	WebSocket bool
+	// WSOrigins are web origins besides the device's own allowed to open
+	// the WebSocket, e.g. "http://localhost:3000"
+	WSOrigins []string
+
+	// StatusInterval is how often status is pushed to connected centrals,
+	// at least MinStatusInterval. Change it with SetStatusInterval once
+	// started.
+	StatusInterval time.Duration
+
+	// MaxStreamFrames caps the frames of a "tags" or "files" response, 0
+	// disables it. A response capped by it or by the request's Limit ends
+	// with {"truncated": true, "next_start": N} and the client asks again
+	// from Start N.
+	MaxStreamFrames int
+
+	// Per-connection protocol state
+	mu           sync.Mutex
+	clients      map[bluetooth.Connection]*clientState
+	activeClient bluetooth.Connection
+	centrals     map[string]connParamsRequester // By address, see hasSubscribers
+	inferredSeen time.Time                      // Last write that kept centrals[""], see sawCentral
+	transfers    int                            // Browser streams running, see beginTransfer
+	stateQueued  map[string]bool                // State event kinds awaiting a refresh
+
+	// Keeps the fragments of concurrent RPC responses apart, see writeRPC
+	rpcMu sync.Mutex
+
+	// Browser characteristic owner, see beginStream
+	streamMu     sync.Mutex
+	streamCancel context.CancelFunc
+	streamGen    uint64
+
+	// Running IMU stream, see updateIMUStream
+	imuCancel context.CancelFunc
+	imuGen    uint64
+
+	// Closed by Stop, ends the background tickers
+	done chan struct{}
+
+	// Signalled by SetStatusInterval to reset the status ticker
+	statusChanged chan struct{}
+
+	// Cancel the Controller subscriptions made by Start
+	unsubscribe []func()
+
+	// Running free space watch, see updateDiskWatch
+	diskWatchCancel context.CancelFunc
+	diskWatchGen    uint64
+
+	// Running Wifi scan, if any
+	scan *wifiScan
+
+	// Buffers being written by s.write (see isEcho)
+	echoes sync.Map
+
+	// Advertisement and the manufacturer data it carries
+	advMu   sync.Mutex
+	adv     *bluetooth.Advertisement
+	advData []byte
+
+	// Browser streams in progress, listed with the Controller's operations
+	ops hardware.Operations
+
+	// Recent status notifications per characteristic, for catch-up
+	replay map[string]*notifyLog
+
 	// Handles
 	battHandle      bluetooth.Characteristic
+	battPowerHandle bluetooth.Characteristic
 	recStatusHandle bluetooth.Characteristic // Replaces statusHandle
 	browserHandle   bluetooth.Characteristic
 
 	// New Status Handles
 	wifiStatusHandle bluetooth.Characteristic
 	diskStatusHandle bluetooth.Characteristic
+
+	protocolHandle  bluetooth.Characteristic
+	scheduleHandle  bluetooth.Characteristic
+	battTimeHandle  bluetooth.Characteristic
+	rpcHandle       bluetooth.Characteristic
+	localeHandle    bluetooth.Characteristic
+	cmdResultHandle bluetooth.Characteristic
+	opEventsHandle  bluetooth.Characteristic
+	configHandle    bluetooth.Characteristic
+	imuHandle       bluetooth.Characteristic
+	storageHandle   bluetooth.Characteristic
+
+	// Device Information values that change when hardware is swapped
+	modelHandle    bluetooth.Characteristic
+	serialHandle   bluetooth.Characteristic
+	hardwareHandle bluetooth.Characteristic
+	softwareHandle bluetooth.Characteristic
+
+	// Last charging state seen, to notify plug/unplug between ticks
+	charging      bool
+	chargingKnown bool
 }
 
 func NewServer(hw hardware.Controller) *Server {
 	return &Server{
-		Adapter: bluetooth.DefaultAdapter,
-		HW:      hw,
+		Adapter:         bluetooth.DefaultAdapter,
+		HW:              hw,
+		CallTimeout:     DefaultCallTimeout,
+		WifiTimeout:     DefaultWifiTimeout,
+		StreamTimeout:   DefaultStreamTimeout,
+		StreamPacing:    DefaultStreamPacing,
+		MaxStreamFrames: DefaultMaxStreamFrames,
+		StatusInterval:  DefaultStatusInterval,
+		statusChanged:   make(chan struct{}, 1),
+		clients:         make(map[bluetooth.Connection]*clientState),
+		centrals:        make(map[string]connParamsRequester),
+		stateQueued:     make(map[string]bool),
+		done:            make(chan struct{}),
+		ops:             hardware.Operations{IDBase: serverOpIDBase},
+		replay: map[string]*notifyLog{
+			replayRecStatus:  {},
+			replayWifiStatus: {},
+			replayDiskStatus: {},
+		},
 	}
 }
 
+// Features lists the optional behaviors this server is set up with, for
+// the startup log.
+func (s *Server) Features() []string {
+	var features []string
+	if s.Format == FormatBinary {
+		features = append(features, "binary_status")
+	}
+	if s.StreamTimeout > 0 {
+		features = append(features, "stream_timeout")
+	}
+	if s.MaxStreamFrames > 0 {
+		features = append(features, "stream_paging")
+	}
+	if debugBuild {
+		features = append(features, "debug_events")
+	}
+	return features
+}
+
 func (s *Server) Start() error {
+	// Must be set before advertising starts for BlueZ to report connections
+	s.Adapter.SetConnectHandler(s.handleConnect)
 	if err := s.Adapter.Enable(); err != nil {
 		return err
 	}
@@ -75,46 +270,165 @@ func (s *Server) Start() error {
 	if err := s.addOwlService(); err != nil {
 		return err
 	}
+	s.updateSchedule()
+	s.updateLocale()
+	s.updateStorageInfo()
+	s.unsubscribe = append(s.unsubscribe,
+		s.HW.SubscribeOperations(s.notifyOperation),
+		s.ops.Subscribe(s.notifyOperation),
+		s.HW.SubscribeState(s.handleStateEvent))
+
+	payload := s.advertisementPayload()
+
+	s.advMu.Lock()
+	defer s.advMu.Unlock()
 
 	adv := s.Adapter.DefaultAdvertisement()
-	err := adv.Configure(bluetooth.AdvertisementOptions{
-		LocalName:    "BlueOWL",
-		ServiceUUIDs: []bluetooth.UUID{ServiceOwlUUID, ServiceBattery},
-	})
-	if err != nil {
+	if err := adv.Configure(advertisementOptions(payload)); err != nil {
 		return err
 	}
 
 	slog.Info("[BLE] Server Started, Advertising...")
-	return adv.Start()
+	if err := adv.Start(); err != nil {
+		return err
+	}
+	s.adv, s.advData = adv, payload
+	return nil
+}
+
+// Stop shuts the server down ahead of the Controller. Advertising stops
+// first so no central connects meanwhile. A recording in progress is
+// stopped so its video is finalized and stays playable. Connected
+// centrals then get a final recorder and disk status.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return // Already stopped
+	default:
+		close(s.done)
+	}
+	unsubscribe := s.unsubscribe
+	s.unsubscribe = nil
+	for _, cancel := range []context.CancelFunc{s.imuCancel, s.diskWatchCancel} {
+		if cancel != nil {
+			cancel()
+		}
+	}
+	s.imuCancel, s.diskWatchCancel = nil, nil
+	s.mu.Unlock()
+
+	s.advMu.Lock()
+	if s.adv != nil {
+		if err := s.adv.Stop(); err != nil {
+			slog.Error("[BLE] Failed to stop advertising", "err", err)
+		}
+		s.adv = nil
+	}
+	s.advMu.Unlock()
+
+	// The final status is sent below, not from the state events
+	for _, cancel := range unsubscribe {
+		cancel()
+	}
+
+	if info, err := callWithTimeout(s.CallTimeout, s.HW.GetRecorderInfo); err == nil && info.FilenameTag != "" {
+		if err := s.call(s.HW.StopRecorder); err != nil {
+			slog.Error("[BLE] Failed to stop recorder", "err", err)
+		} else {
+			slog.Info("[BLE] Recording stopped for shutdown", "tag", info.FilenameTag)
+		}
+	}
+	s.notifyRecStatus()
+	s.notifyDiskStatus()
+	slog.Info("[BLE] Server Stopped")
 }
 
+// Device Information values. The firmware revision comes from the build,
+// see the version package, and the rest from the Controller's
+// GetDeviceInfo, which may replace the model and serial.
+const (
+	manufacturerName = "Augmodo Inc"
+	modelNumber      = "BlueOWL"
+)
+
 func (s *Server) addDeviceInfoService() {
-	serialNum := getSerialNumber()
-	slog.Info("[BLE] Device Info Configured", "serial", serialNum)
+	info := s.deviceInfo()
+	slog.Info("[BLE] Device Info Configured",
+		"model", info.Model,
+		"serial", info.Serial,
+		"firmware", version.String(),
+		"hardware", info.HardwareRevision,
+		"software", info.SoftwareRevision)
 
 	_ = s.Adapter.AddService(&bluetooth.Service{
 		UUID: ServiceDeviceInfo,
 		Characteristics: []bluetooth.CharacteristicConfig{
 			{
 				UUID:  CharManufacturer,
-				Value: []byte("Augmodo Inc"),
+				Value: []byte(manufacturerName),
 				Flags: bluetooth.CharacteristicReadPermission,
 			},
 			{
-				UUID:  CharModel,
-				Value: []byte("BlueOWL v0.1"),
-				Flags: bluetooth.CharacteristicReadPermission,
+				UUID:   CharModel,
+				Value:  []byte(info.Model),
+				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
+				Handle: &s.modelHandle,
 			},
 			{
-				UUID:  CharSerialNumber,
-				Value: []byte(serialNum),
+				UUID:   CharSerialNumber,
+				Value:  []byte(info.Serial),
+				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
+				Handle: &s.serialHandle,
+			},
+			{
+				UUID:  CharFirmware,
+				Value: []byte(version.String()),
 				Flags: bluetooth.CharacteristicReadPermission,
 			},
+			{
+				UUID:   CharHardware,
+				Value:  []byte(info.HardwareRevision),
+				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
+				Handle: &s.hardwareHandle,
+			},
+			{
+				UUID:   CharSoftware,
+				Value:  []byte(info.SoftwareRevision),
+				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
+				Handle: &s.softwareHandle,
+			},
 		},
 	})
 }
 
+// deviceInfo asks the Controller what hardware it drives, falling back to
+// the product's own model and serial. Revisions are left empty if it can't
+// say.
+func (s *Server) deviceInfo() hardware.DeviceInfo {
+	var info hardware.DeviceInfo
+	if hw, err := callWithTimeout(s.CallTimeout, s.HW.GetDeviceInfo); err == nil {
+		info = *hw
+	} else {
+		slog.Warn("[BLE] Device info unavailable", "err", err)
+	}
+	info.Model = cmp.Or(info.Model, modelNumber)
+	info.Serial = cmp.Or(info.Serial, getSerialNumber())
+	return info
+}
+
+// updateDeviceInfo rewrites the Device Information values after hardware
+// was swapped.
+func (s *Server) updateDeviceInfo() {
+	info := s.deviceInfo()
+	s.write(&s.modelHandle, []byte(info.Model))
+	s.write(&s.serialHandle, []byte(info.Serial))
+	s.write(&s.hardwareHandle, []byte(info.HardwareRevision))
+	s.write(&s.softwareHandle, []byte(info.SoftwareRevision))
+	slog.Info("[BLE] Device Info Updated", "model", info.Model, "serial", info.Serial)
+}
+
 func (s *Server) addBatteryService() {
 	_ = s.Adapter.AddService(&bluetooth.Service{
 		UUID: ServiceBattery,
@@ -125,28 +439,83 @@ func (s *Server) addBatteryService() {
 				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
 				Handle: &s.battHandle,
 			},
+			{
+				UUID:   CharBatteryPower,
+				Value:  []byte{0},
+				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
+				Handle: &s.battPowerHandle,
+			},
 		},
 	})
 
-	// Background ticker for periodic updates
+	// Background tickers for periodic updates
 	go func() {
-		ticker := time.NewTicker(30 * time.Second)
-		for range ticker.C {
-			// Battery
-			if status, err := s.HW.GetBatteryStatus(); err == nil {
-				s.battHandle.Write([]byte{status.Percentage})
+		status := time.NewTicker(s.statusInterval())
+		charge := time.NewTicker(chargePollInterval)
+		defer status.Stop()
+		defer charge.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-s.statusChanged:
+				status.Reset(s.statusInterval())
+			case <-status.C:
+				safeCall("status_tick", s.statusTick, nil)
+			case <-charge.C:
+				safeCall("charge_poll", s.pollCharging, nil)
 			}
-			// Update Disk & Wifi status periodically as well
-			s.notifyDiskStatus()
-			s.notifyWifiStatus()
 		}
 	}()
 }
 
+// statusInterval is StatusInterval, raised to MinStatusInterval.
+func (s *Server) statusInterval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return max(s.StatusInterval, MinStatusInterval)
+}
+
+// SetStatusInterval changes how often status is pushed, restarting the
+// period from now.
+func (s *Server) SetStatusInterval(d time.Duration) error {
+	if d < MinStatusInterval {
+		return fmt.Errorf("%w: %v is under %v", ErrInvalidStatusInterval, d, MinStatusInterval)
+	}
+	s.mu.Lock()
+	s.StatusInterval = d
+	s.mu.Unlock()
+
+	select {
+	case s.statusChanged <- struct{}{}:
+	default: // A reset is already pending, and will read d
+	}
+	slog.Info("[BLE] Status interval changed", "interval", d)
+	return nil
+}
+
+func (s *Server) statusTick() {
+	// Scanners still see the advertisement while nobody is connected
+	defer s.refreshAdvertisement()
+	if !s.hasSubscribers() {
+		return
+	}
+
+	// Battery
+	if status, err := callWithTimeout(s.CallTimeout, s.HW.GetBatteryStatus); err == nil {
+		s.updateBattery(status)
+	}
+	// Update Recorder, Disk & Wifi status periodically as well, for what
+	// changes without a state event (disk use and live stats while recording)
+	s.notifyRecStatus()
+	s.notifyDiskStatus()
+	s.notifyWifiStatus()
+}
+
 func (s *Server) addOwlService() error {
 	return s.Adapter.AddService(&bluetooth.Service{
 		UUID: ServiceOwlUUID,
-		Characteristics: []bluetooth.CharacteristicConfig{
+		Characteristics: append([]bluetooth.CharacteristicConfig{
 			// 1. Recorder Status
 			{
 				UUID:   CharRecStatus,
@@ -156,21 +525,21 @@ func (s *Server) addOwlService() error {
 			// 2. Recorder Control
 			{
 				UUID:       CharRecControl,
-				Flags:      bluetooth.CharacteristicWritePermission,
-				WriteEvent: s.handleRecorderCommand,
+				Flags:      bluetooth.CharacteristicWritePermission | bluetooth.CharacteristicWriteWithoutResponsePermission,
+				WriteEvent: s.guard("rec_control", s.handleRecorderCommand),
 			},
 			// 3. Wifi Setup
 			{
 				UUID:       CharWifiSetup,
 				Flags:      bluetooth.CharacteristicWritePermission,
-				WriteEvent: s.handleWifiSetup,
+				WriteEvent: s.guard("wifi_setup", s.handleWifiSetup),
 			},
 			// 4. File Browser
 			{
 				UUID:       CharBrowser,
 				Flags:      bluetooth.CharacteristicWritePermission | bluetooth.CharacteristicIndicatePermission,
 				Handle:     &s.browserHandle,
-				WriteEvent: s.handleBrowserRequest,
+				WriteEvent: s.guard("browser", s.handleBrowserRequest),
 			},
 			// 5. Wifi Status (New)
 			{
@@ -184,16 +553,187 @@ func (s *Server) addOwlService() error {
 				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
 				Handle: &s.diskStatusHandle,
 			},
-		},
+			// 7. Protocol Negotiation
+			{
+				UUID:       CharProtocol,
+				Flags:      bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicWritePermission,
+				Handle:     &s.protocolHandle,
+				WriteEvent: s.guard("protocol", s.handleProtocolSetup),
+			},
+			// 8. Recording Schedule
+			{
+				UUID:   CharSchedule,
+				Flags:  bluetooth.CharacteristicReadPermission,
+				Handle: &s.scheduleHandle,
+			},
+			// 9. Battery Time Remaining
+			{
+				UUID:   CharBatteryTime,
+				Value:  []byte{0, 0},
+				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
+				Handle: &s.battTimeHandle,
+			},
+			// 10. RPC
+			{
+				UUID:       CharRPC,
+				Flags:      bluetooth.CharacteristicWritePermission | bluetooth.CharacteristicIndicatePermission,
+				Handle:     &s.rpcHandle,
+				WriteEvent: s.guard("rpc", s.handleRPC),
+			},
+			// 11. Locale
+			{
+				UUID:       CharLocale,
+				Flags:      bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicWritePermission,
+				Handle:     &s.localeHandle,
+				WriteEvent: s.guard("locale", s.handleLocaleSetup),
+			},
+			// 12. Command Result
+			{
+				UUID:   CharCmdResult,
+				Flags:  bluetooth.CharacteristicNotifyPermission,
+				Handle: &s.cmdResultHandle,
+			},
+			// 13. Operation Events
+			{
+				UUID:       CharOpEvents,
+				Flags:      bluetooth.CharacteristicWritePermission | bluetooth.CharacteristicNotifyPermission,
+				Handle:     &s.opEventsHandle,
+				WriteEvent: s.guard("op_events", s.handleOpEventsFilter),
+			},
+			// 14. Config Import
+			{
+				UUID:       CharConfigImport,
+				Flags:      bluetooth.CharacteristicWritePermission | bluetooth.CharacteristicNotifyPermission,
+				Handle:     &s.configHandle,
+				WriteEvent: s.guard("config_import", s.handleConfigImport),
+			},
+			// 15. IMU Stream
+			{
+				UUID:   CharImuStream,
+				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
+				Handle: &s.imuHandle,
+			},
+			// 16. Time Sync
+			{
+				UUID:       CharTimeSync,
+				Flags:      bluetooth.CharacteristicWritePermission,
+				WriteEvent: s.guard("time_sync", s.handleTimeSync),
+			},
+			// 17. Storage Info
+			{
+				UUID:   CharStorageInfo,
+				Flags:  bluetooth.CharacteristicReadPermission | bluetooth.CharacteristicNotifyPermission,
+				Handle: &s.storageHandle,
+			},
+		}, s.debugCharacteristics()...),
 	})
 }
 
 // --- Handlers ---
 
 type RecCmd struct {
-	Action string                      `json:"action"`
-	Tag    string                      `json:"tag,omitempty"`
-	Config hardware.RecorderParameters `json:"config,omitempty"`
+	RequestID   uint32                      `json:"request_id,omitempty"` // Echoed in the CmdResult
+	Action      string                      `json:"action"`
+	Tag         string                      `json:"tag,omitempty"`
+	Config      hardware.RecorderParameters `json:"config,omitempty"`      // For "config", or a one-off override for "start"
+	Enabled     bool                        `json:"enabled,omitempty"`     // For "auto_restart"
+	Reason      string                      `json:"reason,omitempty"`      // For "start" and "trigger"
+	Operator    string                      `json:"operator,omitempty"`    // For "start"
+	Preallocate bool                        `json:"preallocate,omitempty"` // For "start", fails fast without space
+	Schedule    []hardware.RecordingWindow  `json:"schedule,omitempty"`    // For "schedule", empty clears it
+	ID          *uint32                     `json:"id,omitempty"`          // For "delete", 0 is a valid id
+	Index       *uint32                     `json:"index,omitempty"`       // For "delete" instead of id
+
+	// DryRun, for "delete", "delete_tag", "empty_trash" and "repair",
+	// reports what would be removed in the CmdResult's Plan, removing nothing
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// CmdResult is notified on the Command Result characteristic after every
+// Recorder Control write, so the app can report success or failure. A
+// dry run's list of files can take several MTUs, so it is framed for
+// reassembly as a browser message is (see writeChunked).
+type CmdResult struct {
+	RequestID uint32 `json:"request_id"`
+	Action    string `json:"action,omitempty"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+
+	// What a dry run would remove: a hardware.DeletePlan, or a
+	// hardware.RepairReport for "repair"
+	Plan any `json:"plan,omitempty"`
+}
+
+var errUnknownAction = errors.New("unknown action")
+
+var errNoDryRun = errors.New("action has no dry run")
+
+// removingActions are the Recorder Control actions that remove files. They
+// have dry runs, and run without the CallTimeout watchdog (see
+// callWithTimeout).
+var removingActions = map[string]bool{"delete": true, "delete_tag": true, "empty_trash": true, "repair": true}
+
+func (s *Server) writeCmdResult(result CmdResult) {
+	if data, err := json.Marshal(result); err == nil {
+		s.writeChunked(&s.cmdResultHandle, data)
+	}
+}
+
+var errMissingRecording = errors.New("tag and id or index are required")
+
+// deleteRecording trashes the recording a "delete" command names by id,
+// or else by index in the tag's alphabetical listing. A dry run returns
+// the plan instead.
+func (s *Server) deleteRecording(cmd RecCmd) (*hardware.DeletePlan, error) {
+	if cmd.Tag == "" || (cmd.ID == nil && cmd.Index == nil) {
+		return nil, errMissingRecording
+	}
+	var file *hardware.RecordingFileInfo
+	var err error
+	if cmd.ID != nil {
+		file, err = s.HW.GetRecordingByID(cmd.Tag, *cmd.ID)
+	} else {
+		file, err = s.HW.GetRecordingDetails(cmd.Tag, *cmd.Index)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.DryRun {
+		return nil, s.HW.DeleteRecording(cmd.Tag, file.ID)
+	}
+	if file.InProgress {
+		return nil, hardware.ErrRecordingInProgress
+	}
+	return &hardware.DeletePlan{
+		Tags:  []string{cmd.Tag},
+		Files: []hardware.PlannedFile{{Tag: cmd.Tag, FileName: file.FileName}},
+		Count: 1,
+	}, nil
+}
+
+// deleteTag removes the tag a "delete_tag" command names, or for a dry run
+// returns its recordings instead.
+func (s *Server) deleteTag(cmd RecCmd) (*hardware.DeletePlan, error) {
+	if !cmd.DryRun {
+		_, err := s.HW.DeleteTag(cmd.Tag)
+		return nil, err
+	}
+	return s.HW.PlanDelete(cmd.Tag, 0)
+}
+
+// startOptions maps a "start" command to recorder options. A config sent
+// with it overrides the recorder's for this recording.
+func (cmd RecCmd) startOptions() hardware.StartOptions {
+	opts := hardware.StartOptions{
+		Tag:         cmd.Tag,
+		Reason:      cmd.Reason,
+		Operator:    cmd.Operator,
+		Preallocate: cmd.Preallocate,
+	}
+	if cmd.Config != (hardware.RecorderParameters{}) {
+		opts.Config = &cmd.Config
+	}
+	return opts
 }
 
 func (s *Server) handleRecorderCommand(client bluetooth.Connection, offset int, value []byte) {
@@ -204,23 +744,125 @@ func (s *Server) handleRecorderCommand(client bluetooth.Connection, offset int,
 	var cmd RecCmd
 	if err := json.Unmarshal(value, &cmd); err != nil {
 		slog.Error("[BLE] Invalid JSON in RecControl", "err", err)
+		s.writeCmdResult(CmdResult{Error: "invalid_json"})
 		return
 	}
 
-	switch cmd.Action {
-	case "start":
-		if cmd.Tag == "" {
-			cmd.Tag = "Default"
+	ctx, cancel := context.WithTimeout(context.Background(), s.CallTimeout)
+	defer cancel()
+
+	var snapshot []byte
+	var plan any
+	run := func() error {
+		if cmd.DryRun && !removingActions[cmd.Action] {
+			return errNoDryRun
 		}
-		s.HW.StartRecorder(cmd.Tag)
-	case "stop":
-		s.HW.StopRecorder()
-	case "config":
-		s.HW.SetupRecorder(cmd.Config)
+		switch cmd.Action {
+		case "start":
+			if cmd.Tag == "" {
+				cmd.Tag = "Default"
+			}
+			return s.HW.StartRecorderWithOptions(ctx, cmd.startOptions())
+		case "stop":
+			return s.HW.StopRecorder()
+		case "pause":
+			return s.HW.PauseRecorder()
+		case "resume":
+			return s.HW.ResumeRecorder()
+		case "config":
+			return s.HW.SetupRecorder(cmd.Config)
+		case "auto_restart":
+			return s.HW.SetAutoRestart(cmd.Enabled)
+		case "schedule":
+			return s.HW.SetSchedule(cmd.Schedule)
+		case "trigger":
+			return s.HW.TriggerRecording(cmd.Reason)
+		case "delete":
+			p, err := s.deleteRecording(cmd)
+			plan = p
+			return err
+		case "delete_tag":
+			p, err := s.deleteTag(cmd)
+			plan = p
+			return err
+		case "empty_trash":
+			if cmd.DryRun {
+				p, err := s.HW.PlanEmptyTrash()
+				plan = p
+				return err
+			}
+			_, err := s.HW.EmptyTrash()
+			return err
+		case "repair":
+			if cmd.DryRun {
+				p, err := s.HW.PlanRepair(cmd.Tag)
+				plan = p
+				return err
+			}
+			_, err := s.HW.RepairTag(cmd.Tag)
+			return err
+		case "snapshot":
+			var err error
+			snapshot, err = s.HW.CaptureSnapshot()
+			return err
+		}
+		return errUnknownAction
+	}
+	var err error
+	if removingActions[cmd.Action] && !cmd.DryRun {
+		err = run()
+	} else {
+		err = s.call(run)
+	}
+	result := CmdResult{RequestID: cmd.RequestID, Action: cmd.Action, OK: err == nil}
+	if err == nil && cmd.DryRun {
+		result.Plan = plan
 	}
+	if err != nil {
+		slog.Error("[BLE] Recorder command failed", "action", cmd.Action, "err", err)
+		result.Error = err.Error()
+	}
+	s.writeCmdResult(result)
 
-	// Update recorder status immediately
-	s.notifyRecStatus()
+	if err == nil && snapshot != nil {
+		goSafe("snapshot_stream", func() { s.sendSnapshot(cmd.RequestID, snapshot) }, nil)
+	}
+}
+
+// SnapshotHeader precedes the JPEG of a "snapshot" command on the File
+// Browser characteristic, which then follows as raw binary frames until
+// Bytes have been received, and {} ends the stream.
+type SnapshotHeader struct {
+	RequestID uint32 `json:"request_id"`
+	Snapshot  bool   `json:"snapshot"` // Always true, telling it from browser responses
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Bytes     int    `json:"bytes"`
+}
+
+// sendSnapshot streams a captured JPEG as a browser response.
+func (s *Server) sendSnapshot(requestID uint32, data []byte) {
+	ctx, end := s.beginStream()
+	defer end()
+	s.beginTransfer()
+	defer s.endTransfer()
+	op := s.ops.Begin(OpBrowserStream)
+	defer op.End()
+
+	header := SnapshotHeader{RequestID: requestID, Snapshot: true, Bytes: len(data)}
+	if cfg, err := jpeg.DecodeConfig(bytes.NewReader(data)); err == nil {
+		header.Width, header.Height = cfg.Width, cfg.Height
+	}
+
+	var deadline time.Time
+	if s.StreamTimeout > 0 {
+		deadline = time.Now().Add(s.StreamTimeout)
+	}
+	eos := []byte("{}")
+	if s.writeFrames(ctx, op, binaryFrames(header, data), deadline) {
+		eos = streamEnd(ctx)
+	}
+	s.writeChunked(&s.browserHandle, eos)
 }
 
 func (s *Server) handleWifiSetup(client bluetooth.Connection, offset int, value []byte) {
@@ -230,18 +872,104 @@ func (s *Server) handleWifiSetup(client bluetooth.Connection, offset int, value
 		return
 	}
 	slog.Info("[BLE] Received Wifi Config", "ssid", creds.SSID)
-	s.HW.SetupWifi(creds.SSID, creds.Password)
+	if err := s.call(func() error { return s.HW.SetupWifi(creds.SSID, creds.Password) }); err != nil {
+		slog.Error("[BLE] Failed to save Wifi config", "err", err)
+		return
+	}
 
-	go func() {
-		s.HW.ConnectToWifi()
-		s.notifyWifiStatus() // Update status to show we are connected/connecting
-	}()
+	goSafe("wifi_connect", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.WifiTimeout)
+		defer cancel()
+
+		if err := s.HW.ConnectToWifi(ctx); err != nil {
+			slog.Error("[BLE] Wifi connection failed", "err", err)
+		}
+	}, nil)
 }
 
+// handleLocaleSetup sets the locale written as a plain language tag. The
+// value reads back in canonical form.
+func (s *Server) handleLocaleSetup(client bluetooth.Connection, offset int, value []byte) {
+	locale := strings.TrimSpace(string(value))
+	if err := s.call(func() error { return s.HW.SetLocale(locale) }); err != nil {
+		slog.Error("[BLE] Failed to set locale", "locale", locale, "err", err)
+		s.updateLocale() // Put back the value the write replaced
+	}
+}
+
+type ProtocolRequest struct {
+	Format string `json:"format"` // "json" or "binary"
+	// MTU is the ATT MTU the central negotiated. BlueZ negotiates it on its
+	// own and tinygo doesn't expose it to the peripheral, so the client
+	// reports it here to size our frames.
+	MTU uint16 `json:"mtu,omitempty"`
+}
+
+// ProtocolInfo is the readable value of the negotiation characteristic,
+// showing the effective settings for debugging.
+type ProtocolInfo struct {
+	Format    string `json:"format"`
+	MTU       uint16 `json:"mtu"`
+	ChunkSize int    `json:"chunk_size"`
+}
+
+func (s *Server) handleProtocolSetup(client bluetooth.Connection, offset int, value []byte) {
+	var req ProtocolRequest
+	if err := json.Unmarshal(value, &req); err != nil {
+		slog.Error("[BLE] Invalid JSON in Protocol", "err", err)
+		return
+	}
+
+	var format PayloadFormat
+	switch req.Format {
+	case "", "json":
+		format = FormatJSON
+	case "binary":
+		format = FormatBinary
+	default:
+		slog.Warn("[BLE] Unknown protocol format", "format", req.Format)
+		return
+	}
+
+	s.mu.Lock()
+	c := s.client(client)
+	c.format = format
+	if req.MTU != 0 {
+		c.mtu = req.MTU
+	}
+	info := ProtocolInfo{Format: format.String(), MTU: c.mtu, ChunkSize: payloadSize(c.mtu)}
+	s.activeClient = client
+	s.mu.Unlock()
+
+	slog.Info("[BLE] Protocol negotiated", "client", client, "format", format, "mtu", info.MTU)
+	if data, err := json.Marshal(info); err == nil {
+		s.write(&s.protocolHandle, data)
+	}
+
+	// Re-send current state in the new encoding
+	s.notifyRecStatus()
+	s.notifyWifiStatus()
+	s.notifyDiskStatus()
+}
+
+// BrowserRequest is written to the browser characteristic. Responses are
+// length-prefixed messages (see writeChunked) ending with {}.
 type BrowserRequest struct {
 	Type      string `json:"type"`
 	TagIndex  uint32 `json:"tag_index"`
 	FileIndex uint32 `json:"file_index"`
+	Start     uint32 `json:"start,omitempty"` // First item (the offset) for "tags", "files" and "sessions"
+	Limit     uint32 `json:"limit,omitempty"` // Most items for "tags", "files" and "sessions", within MaxStreamFrames
+	Sort      string `json:"sort,omitempty"`  // File order for "files": "name" (default) or "time"
+	ID        uint32 `json:"id,omitempty"`
+	Before    int64  `json:"before,omitempty"` // Unix time for "delete_before"
+	Since     int64  `json:"since,omitempty"`  // Unix time for "events"
+	Char      string `json:"char,omitempty"`   // Characteristic for "replay"
+	SinceSeq  uint32 `json:"since_seq,omitempty"`
+	DryRun    bool   `json:"dry_run,omitempty"` // For the deletes, "empty_trash" and "repair"
+	Compress  bool   `json:"compress,omitempty"`
+	MaxWidth  uint16 `json:"max_width,omitempty"` // For "thumbnail"
+	Quality   uint8  `json:"quality,omitempty"`   // For "thumbnail", JPEG 1 to 100
 }
 
 func (s *Server) handleBrowserRequest(client bluetooth.Connection, offset int, value []byte) {
@@ -251,94 +979,753 @@ func (s *Server) handleBrowserRequest(client bluetooth.Connection, offset int, v
 		return
 	}
 
-	go func() {
-		switch req.Type {
-		case "tags":
-			count, _ := s.HW.GetNumOfTags()
-			for i := uint32(0); i < count; i++ {
-				tag, _ := s.HW.GetTagInfoByIndex(i)
-				data, _ := json.Marshal(tag)
-				s.browserHandle.Write(data)
-				time.Sleep(50 * time.Millisecond)
+	// Terminate the stream so the client isn't left waiting on a crash
+	onPanic := func() {
+		s.writeChunked(&s.browserHandle, []byte(`{"error": "internal"}`))
+		s.writeChunked(&s.browserHandle, []byte("{}"))
+	}
+
+	// Wifi scans stream results as they are found
+	switch req.Type {
+	case "wifi_scan":
+		goSafe("wifi_scan", s.streamWifiScan, onPanic)
+		return
+	case "wifi_scan_cancel":
+		if !s.cancelWifiScan() {
+			goSafe("wifi_scan_cancel", func() {
+				s.streamMu.Lock() // Answer after, not instead of, a running stream
+				defer s.streamMu.Unlock()
+				s.writeChunked(&s.browserHandle, []byte(`{"error": "no_scan_running"}`))
+				s.writeChunked(&s.browserHandle, []byte("{}"))
+			}, nil)
+		}
+		return
+	case "thumbnail":
+		req.Compress = false // Binary frames, and JPEG doesn't deflate anyway
+	}
+
+	goSafe("browser_stream", func() {
+		ctx, end := s.beginStream()
+		defer end()
+		s.beginTransfer()
+		defer s.endTransfer()
+		op := s.ops.Begin(OpBrowserStream)
+		defer op.End()
+
+		var page browserPage
+		var err error
+		if timeout := s.browserCallTimeout(req.Type); timeout > 0 {
+			page, err = callWithTimeout(timeout, func() (browserPage, error) {
+				return s.browserFrames(req), nil
+			})
+		} else {
+			page = s.browserFrames(req)
+		}
+		if err != nil {
+			slog.Error("[BLE] Browser request failed", "type", req.Type, "err", err)
+			page = browserPage{frames: [][]byte{errorFrame(err)}}
+		}
+		frames := page.frames
+
+		// Bounds sending, however long the frames took to collect
+		var deadline time.Time
+		if s.StreamTimeout > 0 {
+			deadline = time.Now().Add(s.StreamTimeout)
+		}
+
+		cut := false
+		if req.Compress {
+			cut = s.writeCompressed(ctx, op, frames, deadline)
+		} else {
+			cut = s.writeFrames(ctx, op, frames, deadline)
+		}
+
+		eos := []byte("{}")
+		if page.truncated {
+			eos, _ = json.Marshal(map[string]any{"truncated": true, "next_start": page.next})
+		}
+		switch {
+		case cut && ctx.Err() != nil:
+			slog.Info("[BLE] Browser stream cancelled by a newer request", "type", req.Type)
+			eos = streamEnd(ctx)
+		case cut:
+			slog.Warn("[BLE] Browser stream timed out", "type", req.Type, "timeout", s.StreamTimeout)
+			eos = streamEnd(ctx)
+		}
+		s.writeChunked(&s.browserHandle, eos)
+	}, onPanic)
+}
+
+// writeFrames sends frames on the browser characteristic, reporting
+// progress on op. It reports whether streamCut cut it short.
+func (s *Server) writeFrames(ctx context.Context, op *hardware.OperationHandle, frames [][]byte, deadline time.Time) (cut bool) {
+	for i, data := range frames {
+		op.SetProgress(i, len(frames))
+		if streamCut(ctx, deadline) {
+			return true
+		}
+		if err := s.writeChunked(&s.browserHandle, data); err != nil {
+			// The client can't reassemble past a broken frame
+			s.writeChunked(&s.browserHandle, []byte(`{"error": "write_failed"}`))
+			return false
+		}
+		s.pace(ctx)
+	}
+	return false
+}
+
+// binaryFrames is a JSON header followed by data, split into as few
+// frames as the length header allows.
+func binaryFrames(header any, data []byte) [][]byte {
+	head, _ := json.Marshal(header)
+	frames := [][]byte{head}
+	for len(data) > 0 {
+		n := min(len(data), maxFrameSize)
+		frames = append(frames, data[:n])
+		data = data[n:]
+	}
+	return frames
+}
+
+// browserPage is a browser response, cut short at MaxStreamFrames.
+type browserPage struct {
+	frames    [][]byte
+	truncated bool
+	next      uint32 // Start of the next page when truncated
+}
+
+// pageLimit is the most frames a "tags" or "files" response holds: the
+// request's limit, within the server's MaxStreamFrames. 0 is unlimited.
+func (s *Server) pageLimit(req BrowserRequest) int {
+	limit := int(req.Limit)
+	if s.MaxStreamFrames > 0 && (limit == 0 || limit > s.MaxStreamFrames) {
+		limit = s.MaxStreamFrames
+	}
+	return limit
+}
+
+// browserCallTimeout bounds collecting the frames of a browser request, 0
+// to wait however long it takes, as requests that change files do (see
+// callWithTimeout).
+func (s *Server) browserCallTimeout(reqType string) time.Duration {
+	switch reqType {
+	case "delete_before", "delete_tag_recordings", "empty_trash", "restore", "repair":
+		return 0
+	case "thumbnail":
+		return thumbnailGenerateTimeout // May have to generate it
+	case "manifest":
+		return tagManifestTimeout
+	}
+	return s.CallTimeout
+}
+
+// capped reports whether a listing of n frames reached the page limit,
+// recording where the next page starts.
+func (p *browserPage) capped(limit, n int, next uint32) bool {
+	if limit <= 0 || n < limit {
+		return false
+	}
+	p.truncated, p.next = true, next
+	return true
+}
+
+// browserFrames collects the JSON frames answering a browser request.
+func (s *Server) browserFrames(req BrowserRequest) browserPage {
+	var page browserPage
+	var frames [][]byte
+
+	switch req.Type {
+	case "tags":
+		limit := s.pageLimit(req)
+		count, _ := s.HW.GetNumOfTags()
+		for i := req.Start; i < count; i++ {
+			if page.capped(limit, len(frames), i) {
+				break
 			}
+			tag, _ := s.HW.GetTagInfoByIndex(i)
+			data, _ := json.Marshal(tag)
+			frames = append(frames, data)
+		}
 
-		case "files":
-			tagInfo, _ := s.HW.GetTagInfoByIndex(req.TagIndex)
-			if tagInfo != nil {
-				for i := uint32(0); i < tagInfo.NumOfRecordings; i++ {
-					file, _ := s.HW.GetRecordingDetails(tagInfo.Name, i)
-					data, _ := json.Marshal(file)
-					s.browserHandle.Write(data)
-					time.Sleep(50 * time.Millisecond)
-				}
+	case "files":
+		sortBy := hardware.SortBy(req.Sort)
+		if !sortBy.Valid() {
+			frames = append(frames, errorFrame(hardware.ErrInvalidSort))
+			break
+		}
+		tagInfo, _ := s.HW.GetTagInfoByIndex(req.TagIndex)
+		if tagInfo == nil {
+			break
+		}
+		limit := s.pageLimit(req)
+		files, err := s.HW.ListRecordingsSorted(tagInfo.Name, req.Start, uint32(limit), sortBy)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		for _, file := range files {
+			data, _ := json.Marshal(file)
+			frames = append(frames, data)
+		}
+		if next := req.Start + uint32(limit); limit > 0 && next < tagInfo.NumOfRecordings {
+			page.truncated, page.next = true, next
+		}
+
+	case "sessions":
+		tagInfo, err := s.HW.GetTagInfoByIndex(req.TagIndex)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		sessions, err := s.HW.ListSessions(tagInfo.Name)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		limit := s.pageLimit(req)
+		for i := req.Start; i < uint32(len(sessions)); i++ {
+			if page.capped(limit, len(frames), i) {
+				break
 			}
+			data, _ := json.Marshal(sessions[i])
+			frames = append(frames, data)
+		}
 
-		default:
-			slog.Warn("[BLE] Unknown browser request type", "type", req.Type)
-			s.browserHandle.Write([]byte(`{"error": "unknown_type"}`))
+	case "tag_usage":
+		tagInfo, err := s.HW.GetTagInfoByIndex(req.TagIndex)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
 		}
+		used, err := s.HW.GetTagDiskUsage(tagInfo.Name)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		data, _ := json.Marshal(TagUsagePayload{Tag: tagInfo.Name, Bytes: used})
+		frames = append(frames, data)
 
-		eos := []byte("{}")
-		s.browserHandle.Write(eos)
-	}()
+	case "repair":
+		tagInfo, err := s.HW.GetTagInfoByIndex(req.TagIndex)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		repair := s.HW.RepairTag
+		if req.DryRun {
+			repair = s.HW.PlanRepair
+		}
+		report, err := repair(tagInfo.Name)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		data, _ := json.Marshal(report)
+		frames = append(frames, data)
+
+	case "config":
+		data, err := s.HW.ExportConfig()
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		frames = append(frames, data)
+
+	case "operations":
+		ops, err := s.activeOperations()
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		for _, op := range ops {
+			data, _ := json.Marshal(op)
+			frames = append(frames, data)
+		}
+
+	case "thumbnail":
+		frames = append(frames, s.thumbnailFrames(req)...)
+
+	case "manifest":
+		// One frame per recording after a header, so a large tag streams
+		tagInfo, err := s.HW.GetTagInfoByIndex(req.TagIndex)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		manifest, err := s.HW.GetTagManifest(tagInfo.Name)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		header, _ := json.Marshal(map[string]any{
+			"tag":         manifest.Tag,
+			"count":       manifest.Count,
+			"total_bytes": manifest.TotalBytes,
+		})
+		frames = append(frames, header)
+		for _, entry := range manifest.Recordings {
+			data, _ := json.Marshal(entry)
+			frames = append(frames, data)
+		}
+
+	case "restore":
+		if err := s.HW.RestoreRecording(req.ID); err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		data, _ := json.Marshal(map[string]uint32{"restored": req.ID})
+		frames = append(frames, data)
+
+	case "empty_trash":
+		if req.DryRun {
+			plan, err := s.HW.PlanEmptyTrash()
+			if err != nil {
+				frames = append(frames, errorFrame(err))
+				break
+			}
+			frames = append(frames, planFrames(plan)...)
+			break
+		}
+		count, err := s.HW.EmptyTrash()
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		data, _ := json.Marshal(map[string]uint32{"removed": count})
+		frames = append(frames, data)
+
+	case "events":
+		events, err := s.HW.GetRecorderEvents(req.Since)
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		for _, ev := range events {
+			data, _ := json.Marshal(ev)
+			frames = append(frames, data)
+		}
+
+	case "status":
+		status, err := s.allStatus()
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		data, _ := json.Marshal(status)
+		frames = append(frames, data)
+
+	case "replay":
+		frames = append(frames, s.replayFrames(req.Char, req.SinceSeq)...)
+
+	case "stats":
+		stats, err := s.HW.GetRuntimeStats()
+		if err != nil {
+			frames = append(frames, errorFrame(err))
+			break
+		}
+		data, _ := json.Marshal(stats)
+		frames = append(frames, data)
+
+	case "delete_before", "delete_tag_recordings":
+		frames = append(frames, s.bulkDeleteFrames(req)...)
+
+	default:
+		slog.Warn("[BLE] Unknown browser request type", "type", req.Type)
+		frames = append(frames, []byte(`{"error": "unknown_type"}`))
+	}
+
+	page.frames = frames
+	return page
+}
+
+// ThumbnailHeader precedes the JPEG of a "thumbnail" response, which then
+// follows as raw binary frames until Bytes have been received.
+type ThumbnailHeader struct {
+	Tag    string `json:"tag"`
+	Index  uint32 `json:"index"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Bytes  int    `json:"bytes"`
+}
+
+// thumbnailFrames fetches a recording's thumbnail and splits it into frames.
+func (s *Server) thumbnailFrames(req BrowserRequest) [][]byte {
+	tagInfo, err := s.HW.GetTagInfoByIndex(req.TagIndex)
+	if err != nil {
+		return [][]byte{errorFrame(err)}
+	}
+	opts := hardware.ThumbnailOptions{MaxWidth: req.MaxWidth, Quality: req.Quality}
+	thumb, err := s.HW.GetThumbnail(tagInfo.Name, req.FileIndex, opts)
+	if err != nil {
+		return [][]byte{errorFrame(err)}
+	}
+
+	return binaryFrames(ThumbnailHeader{
+		Tag:    tagInfo.Name,
+		Index:  req.FileIndex,
+		Width:  thumb.Width,
+		Height: thumb.Height,
+		Bytes:  len(thumb.Data),
+	}, thumb.Data)
+}
+
+// planFrames lists the files of a dry run, one frame each, then a summary.
+func planFrames(plan *hardware.DeletePlan) [][]byte {
+	var frames [][]byte
+	for _, f := range plan.Files {
+		data, _ := json.Marshal(f)
+		frames = append(frames, data)
+	}
+	data, _ := json.Marshal(map[string]any{"dry_run": true, "count": plan.Count, "tags": plan.Tags})
+	return append(frames, data)
+}
+
+// bulkDeleteFrames runs a bulk delete, or lists its targets for a dry run.
+func (s *Server) bulkDeleteFrames(req BrowserRequest) [][]byte {
+	tag := ""
+	if req.Type == "delete_tag_recordings" {
+		tagInfo, err := s.HW.GetTagInfoByIndex(req.TagIndex)
+		if err != nil {
+			return [][]byte{errorFrame(err)}
+		}
+		tag = tagInfo.Name
+	}
+
+	if req.DryRun {
+		plan, err := s.HW.PlanDelete(tag, req.Before)
+		if err != nil {
+			return [][]byte{errorFrame(err)}
+		}
+
+		return planFrames(plan)
+	}
+
+	var count uint32
+	var err error
+	if tag == "" {
+		count, err = s.HW.DeleteRecordingsBefore(req.Before)
+	} else {
+		count, err = s.HW.DeleteTagRecordings(tag)
+	}
+	if err != nil {
+		return [][]byte{errorFrame(err)}
+	}
+
+	data, _ := json.Marshal(map[string]uint32{"deleted": count})
+	return [][]byte{data}
 }
 
 // --- Helpers ---
 
+// guard wraps a WriteEvent handler so a panic is logged instead of taking
+// down the process, and drops the server's own writes echoed back into it.
+func (s *Server) guard(name string, h func(bluetooth.Connection, int, []byte)) func(bluetooth.Connection, int, []byte) {
+	return func(client bluetooth.Connection, offset int, value []byte) {
+		if s.isEcho(value) {
+			return
+		}
+		s.sawCentral()
+		safeCall(name, func() { h(client, offset, value) }, nil)
+	}
+}
+
+// charWriter is the part of bluetooth.Characteristic that s.write uses.
+type charWriter interface {
+	Write(p []byte) (int, error)
+}
+
+// write updates a characteristic value (notifying/indicating subscribers).
+// tinygo's BlueZ backend also feeds the value to the characteristic's own
+// WriteEvent, so the buffer is marked in flight for guard to recognise it.
+//
+// A write replaces the whole value, so when the stack takes fewer bytes
+// than given the value is written again once rather than continued. A
+// second short write is returned as io.ErrShortWrite.
+func (s *Server) write(h charWriter, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	s.echoes.Store(&data[0], struct{}{})
+	defer s.echoes.Delete(&data[0])
+
+	var err error
+	for range 2 {
+		var n int
+		n, err = h.Write(data)
+		if err == nil && n < len(data) {
+			err = fmt.Errorf("%w: %d of %d bytes", io.ErrShortWrite, n, len(data))
+		}
+		if !errors.Is(err, io.ErrShortWrite) {
+			break
+		}
+	}
+	if err != nil {
+		slog.Warn("[BLE] Characteristic write failed", "bytes", len(data), "err", err)
+	}
+	return err
+}
+
+// isEcho reports whether value is a buffer currently being written by s.write.
+func (s *Server) isEcho(value []byte) bool {
+	if len(value) == 0 {
+		return false
+	}
+	_, ok := s.echoes.Load(&value[0])
+	return ok
+}
+
+// goSafe runs fn in a new goroutine with panic recovery.
+func goSafe(name string, fn func(), onPanic func()) {
+	go safeCall(name, fn, onPanic)
+}
+
+// safeCall runs fn, logging any panic with its stack. onPanic (optional) lets
+// the caller tell the client something went wrong.
+func safeCall(name string, fn func(), onPanic func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("[BLE] Recovered from panic", "handler", name, "panic", r, "stack", string(debug.Stack()))
+			if onPanic != nil {
+				safeCall(name+"_onpanic", onPanic, nil)
+			}
+		}
+	}()
+	fn()
+}
+
+// errorFrame builds the {"error": "..."} frame sent back on failures.
+func errorFrame(err error) []byte {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return data
+}
+
+// clientState holds the protocol preferences negotiated by one connection.
+type clientState struct {
+	format   PayloadFormat
+	mtu      uint16
+	opFilter map[string]bool // Operation types notified, nil for all
+	config   *frameReader    // Config import being received
+}
+
+// client returns the state for a connection, creating it with the server
+// defaults on first use. Caller must hold s.mu.
+func (s *Server) client(conn bluetooth.Connection) *clientState {
+	c, ok := s.clients[conn]
+	if !ok {
+		c = &clientState{format: s.Format, mtu: DefaultMTU}
+		s.clients[conn] = c
+	}
+	return c
+}
+
+// notifyFormat returns the encoding for outgoing notifications.
+// Characteristic.Write fans out to every subscriber, so notifications follow
+// the connection that negotiated most recently (BlueZ reports every write as
+// Connection 0, so in practice this is the single connected central).
+func (s *Server) notifyFormat() PayloadFormat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clients[s.activeClient]; ok {
+		return c.format
+	}
+	return s.Format
+}
+
+// chunkSize returns how many bytes fit in one notification/indication for
+// the active connection.
+func (s *Server) chunkSize() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clients[s.activeClient]; ok {
+		return payloadSize(c.mtu)
+	}
+	return payloadSize(DefaultMTU)
+}
+
 // Split Payloads
 type RecStatusPayload struct {
 	IsRecording bool   `json:"is_recording"`
+	AutoRestart bool   `json:"auto_restart"`
+	Paused      bool   `json:"paused"`
 	Tag         string `json:"tag"`
 	FPS         uint8  `json:"fps"`
 	Bitrate     uint32 `json:"bitrate"`
+
+	// Measured by the encoder, see hardware.RecorderLiveStats
+	MeasuredFPS     float32 `json:"measured_fps"`
+	MeasuredBitrate uint32  `json:"measured_bitrate"`
+	DroppedFrames   uint32  `json:"dropped_frames"`
+	EncodeErrors    uint32  `json:"encode_errors"`
+
+	Error       string `json:"error,omitempty"` // Why the recorder last stopped on its own
+	DeviceUnix  int64  `json:"device_unix"`     // Device clock, to check a time sync
+	ElapsedSecs uint32 `json:"elapsed_secs"`    // Since the recording started, 0 when idle
+	Seq         uint32 `json:"seq"`             // Per-characteristic notification counter
+}
+
+type TagUsagePayload struct {
+	Tag   string `json:"tag"`
+	Bytes uint64 `json:"bytes"`
 }
 
 type WifiStatusPayload struct {
 	SSID      string `json:"ssid"`
-	Connected bool   `json:"connected"`
+	Connected bool   `json:"connected"`       // The link is up right now
+	State     string `json:"state,omitempty"` // hardware.WifiConnected etc., empty if the link can't be checked
+	IPAddress string `json:"ip_address,omitempty"`
+	RSSI      int8   `json:"rssi,omitempty"`       // dBm while connected
+	Error     string `json:"last_error,omitempty"` // Why the last connection failed
+	Seq       uint32 `json:"seq"`
+}
+
+type DiskStatusPayload struct {
+	hardware.DiskStatus
+	Seq uint32 `json:"seq"`
 }
 
 func (s *Server) notifyRecStatus() {
-	info, err := s.HW.GetRecorderInfo()
+	if !s.hasSubscribers() {
+		return
+	}
+	payload, err := s.recStatusPayload()
 	if err != nil {
 		return
 	}
 
+	seqd := s.recordNotify(replayRecStatus, payload)
+	if data, err := encodePayload(s.notifyFormat(), seqd); err == nil {
+		s.write(&s.recStatusHandle, data)
+	}
+}
+
+// recStatusPayload builds the recorder status, without a sequence number.
+func (s *Server) recStatusPayload() (RecStatusPayload, error) {
+	info, err := callWithTimeout(s.CallTimeout, s.HW.GetRecorderInfo)
+	if err != nil {
+		return RecStatusPayload{}, err
+	}
+
+	live, err := callWithTimeout(s.CallTimeout, s.HW.GetRecorderLiveStats)
+	if err != nil {
+		return RecStatusPayload{}, err
+	}
+
 	isRec := info.FilenameTag != ""
 	payload := RecStatusPayload{
-		IsRecording: isRec,
-		Tag:         info.FilenameTag,
-		FPS:         info.FPS,
-		Bitrate:     info.Bitrate,
+		IsRecording:     isRec,
+		AutoRestart:     info.AutoRestart,
+		Paused:          info.Paused,
+		Tag:             info.FilenameTag,
+		FPS:             info.FPS,
+		Bitrate:         info.Bitrate,
+		MeasuredFPS:     live.FPS,
+		MeasuredBitrate: live.Bitrate,
+		DroppedFrames:   live.DroppedFrames,
+		EncodeErrors:    live.EncodeErrors,
+		Error:           info.LastError,
+	}
+	if now, err := callWithTimeout(s.CallTimeout, s.HW.GetSystemTime); err == nil {
+		payload.DeviceUnix = now.Unix()
+	}
+	if isRec && info.StartedUnix > 0 && payload.DeviceUnix > info.StartedUnix {
+		payload.ElapsedSecs = uint32(payload.DeviceUnix - info.StartedUnix)
 	}
+	return payload, nil
+}
 
-	if data, err := json.Marshal(payload); err == nil {
-		s.recStatusHandle.Write(data)
+// updateSchedule refreshes the value of the schedule characteristic.
+func (s *Server) updateSchedule() {
+	windows, err := callWithTimeout(s.CallTimeout, s.HW.GetSchedule)
+	if err != nil {
+		return
+	}
+	if windows == nil {
+		windows = []hardware.RecordingWindow{}
+	}
+	if data, err := json.Marshal(windows); err == nil {
+		s.write(&s.scheduleHandle, data)
+	}
+}
+
+// updateLocale refreshes the value of the locale characteristic.
+func (s *Server) updateLocale() {
+	locale, err := callWithTimeout(s.CallTimeout, s.HW.GetLocale)
+	if err != nil {
+		return
+	}
+	s.write(&s.localeHandle, []byte(locale))
+}
+
+// updateStorageInfo refreshes the value of the storage info characteristic.
+func (s *Server) updateStorageInfo() {
+	info, err := callWithTimeout(s.CallTimeout, s.HW.GetStorageInfo)
+	if err != nil {
+		return
+	}
+	if data, err := json.Marshal(info); err == nil {
+		s.write(&s.storageHandle, data)
 	}
 }
 
 func (s *Server) notifyWifiStatus() {
-	params, _ := s.HW.GetWifiDetails()
-	connected := params.SSID != "" // Simple check for now
+	if !s.hasSubscribers() {
+		return
+	}
+	payload, err := s.wifiStatusPayload()
+	if err != nil {
+		return
+	}
 
-	payload := WifiStatusPayload{
-		SSID:      params.SSID,
-		Connected: connected,
+	seqd := s.recordNotify(replayWifiStatus, payload)
+	if data, err := encodePayload(s.notifyFormat(), seqd); err == nil {
+		s.write(&s.wifiStatusHandle, data)
 	}
-	if data, err := json.Marshal(payload); err == nil {
-		s.wifiStatusHandle.Write(data)
+}
+
+// wifiStatusPayload builds the wifi status, without a sequence number. The
+// password is never sent back.
+func (s *Server) wifiStatusPayload() (WifiStatusPayload, error) {
+	params, err := callWithTimeout(s.CallTimeout, s.HW.GetWifiDetails)
+	if err != nil {
+		return WifiStatusPayload{}, err
+	}
+	payload := WifiStatusPayload{SSID: params.SSID, Error: params.LastError}
+
+	// Saved credentials or a past success don't mean the link is up now
+	link, err := callWithTimeout(s.CallTimeout, s.HW.GetWifiStatus)
+	if err != nil {
+		slog.Warn("[BLE] Failed to check the Wifi link", "err", err)
+		return payload, nil
 	}
+	payload.Connected = link.State == hardware.WifiConnected
+	payload.State = link.State
+	payload.IPAddress = link.IPAddress
+	payload.RSSI = link.RSSI
+	return payload, nil
 }
 
 func (s *Server) notifyDiskStatus() {
-	disk, err := s.HW.GetDiskStatus()
+	if !s.hasSubscribers() {
+		return
+	}
+	payload, err := s.diskStatusPayload()
 	if err != nil {
 		return
 	}
 
-	if data, err := json.Marshal(disk); err == nil {
-		s.diskStatusHandle.Write(data)
+	seqd := s.recordNotify(replayDiskStatus, payload)
+	if data, err := encodePayload(s.notifyFormat(), seqd); err == nil {
+		s.write(&s.diskStatusHandle, data)
+	}
+}
+
+// diskStatusPayload builds the disk status, without a sequence number.
+func (s *Server) diskStatusPayload() (DiskStatusPayload, error) {
+	disk, err := callWithTimeout(s.CallTimeout, s.HW.GetDiskStatus)
+	if err != nil {
+		return DiskStatusPayload{}, err
 	}
+	return DiskStatusPayload{DiskStatus: *disk}, nil
 }
 
 func getSerialNumber() string {
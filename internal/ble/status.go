@@ -0,0 +1,71 @@
+package ble
+
+import (
+	"blueowl-ble/internal/hardware"
+	"blueowl-ble/internal/version"
+)
+
+// AllStatusPayload gathers every status in one object for a reconnecting
+// client. The Seq of each status is the last one notified, so "replay"
+// requests can continue from there. At close to 1 KB it's several MTUs:
+// both the "status" browser request and get_status send it as a framed,
+// fragmented message (see RPCResponse).
+type AllStatusPayload struct {
+	Recorder RecStatusPayload        `json:"recorder"`
+	Wifi     WifiStatusPayload       `json:"wifi"`
+	Disk     DiskStatusPayload       `json:"disk"`
+	Battery  *hardware.BatteryStatus `json:"battery"`
+	Device   DeviceInfoPayload       `json:"device"`
+}
+
+type DeviceInfoPayload struct {
+	Manufacturer string `json:"manufacturer"`
+	Model        string `json:"model"`
+	Serial       string `json:"serial"`
+	Firmware     string `json:"firmware"`
+	Hardware     string `json:"hardware"`
+	Software     string `json:"software"`
+}
+
+// allStatus builds an AllStatusPayload from the notify payload builders.
+func (s *Server) allStatus() (*AllStatusPayload, error) {
+	rec, err := s.recStatusPayload()
+	if err != nil {
+		return nil, err
+	}
+	wifi, err := s.wifiStatusPayload()
+	if err != nil {
+		return nil, err
+	}
+	disk, err := s.diskStatusPayload()
+	if err != nil {
+		return nil, err
+	}
+	battery, err := callWithTimeout(s.CallTimeout, s.HW.GetBatteryStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	info := s.deviceInfo()
+
+	s.mu.Lock()
+	rec.Seq = s.replay[replayRecStatus].seq
+	wifi.Seq = s.replay[replayWifiStatus].seq
+	disk.Seq = s.replay[replayDiskStatus].seq
+	s.mu.Unlock()
+
+	return &AllStatusPayload{
+		Recorder: rec,
+		Wifi:     wifi,
+		Disk:     disk,
+		Battery:  battery,
+		Device: DeviceInfoPayload{
+			Manufacturer: manufacturerName,
+			Model:        info.Model,
+			Serial:       info.Serial,
+			Firmware:     version.String(),
+			Hardware:     info.HardwareRevision,
+			Software:     info.SoftwareRevision,
+		},
+	}, nil
+}
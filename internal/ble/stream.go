@@ -0,0 +1,67 @@
+package ble
+
+import (
+	"context"
+	"time"
+)
+
+// beginStream claims the browser characteristic for a new response. The
+// response in progress, if any, is cancelled, and beginStream waits for
+// it to stop writing, so frames of two responses never interleave. The
+// returned context is cancelled when a later response takes over; end
+// releases the characteristic.
+func (s *Server) beginStream() (ctx context.Context, end func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	if s.streamCancel != nil {
+		s.streamCancel()
+	}
+	s.streamCancel = cancel
+	s.streamGen++
+	gen := s.streamGen
+	s.mu.Unlock()
+
+	s.streamMu.Lock()
+	return ctx, func() {
+		s.streamMu.Unlock()
+		cancel()
+		s.mu.Lock()
+		if s.streamGen == gen {
+			s.streamCancel = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// DefaultStreamPacing is the gap between browser frames, enough for the
+// slowest centrals tested to keep up.
+const DefaultStreamPacing = 50 * time.Millisecond
+
+// pace waits StreamPacing between two frames of a response, or until ctx
+// is cancelled.
+func (s *Server) pace(ctx context.Context) {
+	if s.StreamPacing <= 0 {
+		return
+	}
+	t := time.NewTimer(s.StreamPacing)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
+
+// streamCut reports whether a response should stop: a later one took over
+// or its deadline passed.
+func streamCut(ctx context.Context, deadline time.Time) bool {
+	return ctx.Err() != nil || pastDeadline(deadline)
+}
+
+// streamEnd is the end of a response cut short by streamCut.
+func streamEnd(ctx context.Context) []byte {
+	if ctx.Err() != nil {
+		return []byte(`{"cancelled": true}`)
+	}
+	return []byte(`{"timeout": true}`)
+}
@@ -0,0 +1,63 @@
+package ble
+
+import (
+	"log/slog"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// Notifications can't be gated per characteristic: BlueZ keeps the CCCD
+// state itself, and tinygo's GATT server doesn't implement the StartNotify
+// and StopNotify calls it would use to tell the application. The server
+// tracks connected centrals instead and, while one is connected, treats
+// every notifying characteristic as subscribed, so a central subscribed to
+// one characteristic still has every notification encoded and sent.
+// Characteristic values aren't kept current meanwhile; a connecting central
+// gets a fresh status tick so its reads aren't stale.
+
+// handleConnect is the adapter's connect handler.
+func (s *Server) handleConnect(device bluetooth.Device, connected bool) {
+	addr := device.Address.String()
+
+	s.mu.Lock()
+	if connected {
+		s.centrals[addr] = device
+	} else {
+		delete(s.centrals, addr)
+		delete(s.centrals, "") // Inferred by sawCentral, now accounted for
+	}
+	n := len(s.centrals)
+	s.mu.Unlock()
+
+	slog.Info("[BLE] Central connection changed", "addr", addr, "connected", connected, "centrals", n)
+	if connected {
+		goSafe("connect_refresh", s.statusTick, nil)
+	}
+	s.updateIMUStream()
+}
+
+// sawCentral records that some central is connected because it wrote to a
+// characteristic, for backends that don't report connections. Without a
+// disconnect to remove it, the inferred central expires once it hasn't
+// written for a status interval; see hasSubscribers.
+func (s *Server) sawCentral() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.centrals[""]; ok || len(s.centrals) == 0 {
+		s.centrals[""] = nil
+		s.inferredSeen = time.Now()
+	}
+}
+
+// hasSubscribers reports whether any central is connected. It stands in
+// for whether a notification would reach anyone, which BlueZ doesn't tell.
+func (s *Server) hasSubscribers() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.centrals[""]; ok && time.Since(s.inferredSeen) > max(s.StatusInterval, MinStatusInterval) {
+		delete(s.centrals, "")
+		slog.Info("[BLE] Inferred central expired", "idle", time.Since(s.inferredSeen).Round(time.Second))
+	}
+	return len(s.centrals) > 0
+}
@@ -0,0 +1,94 @@
+package ble
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultWifiTimeout bounds a Wifi connection attempt. Joining a network
+// takes longer than a Controller call, so it has its own limit.
+const DefaultWifiTimeout = 30 * time.Second
+
+// DefaultStreamTimeout bounds sending one browser response, so a slow or
+// stuck client can't hold a stream open indefinitely.
+const DefaultStreamTimeout = 2 * time.Minute
+
+// DefaultMaxStreamFrames caps a "tags" or "files" response, so huge tags
+// are paged instead of tying up the browser for minutes.
+const DefaultMaxStreamFrames = 1000
+
+// storageBenchmarkTimeout bounds BenchmarkStorage, which writes tens of MB
+// to a card that may be slow: finding that out is the point.
+const storageBenchmarkTimeout = time.Minute
+
+// recordingChecksumTimeout bounds ChecksumRecording, which reads a whole
+// video off the card unless its checksum is cached.
+const recordingChecksumTimeout = 2 * time.Minute
+
+// tagManifestTimeout bounds GetTagManifest, which checksums every
+// recording of the tag not yet cached: minutes for a full tag on an SD
+// card. Its progress is sent as operation events meanwhile.
+const tagManifestTimeout = 15 * time.Minute
+
+// thumbnailGenerateTimeout bounds GenerateThumbnail, which decodes the
+// start of a video.
+const thumbnailGenerateTimeout = 30 * time.Second
+
+// DefaultStatusInterval is how often status is pushed to connected
+// centrals, for what changes without a state event.
+const DefaultStatusInterval = 30 * time.Second
+
+// MinStatusInterval keeps a short status interval from busy-looping the
+// Controller.
+const MinStatusInterval = time.Second
+
+// ErrInvalidStatusInterval is returned for an interval under
+// MinStatusInterval.
+var ErrInvalidStatusInterval = errors.New("status interval too short")
+
+// DefaultCallTimeout bounds a single Controller call made from a BLE handler.
+const DefaultCallTimeout = 10 * time.Second
+
+// ErrControllerTimeout is returned when a Controller call doesn't finish in time.
+var ErrControllerTimeout = errors.New("controller call timed out")
+
+// callWithTimeout runs fn on a watchdog goroutine and gives up after d.
+// A timed-out call keeps running in the background; its result is dropped.
+// Calls that remove or change files shouldn't be watched: the client would
+// be told the change failed, and then it goes through.
+func callWithTimeout[T any](d time.Duration, fn func() (T, error)) (T, error) {
+	type result struct {
+		val T
+		err error
+	}
+
+	done := make(chan result, 1)
+	goSafe("controller_call", func() {
+		v, err := fn()
+		done <- result{v, err}
+	}, nil)
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.val, r.err
+	case <-timer.C:
+		var zero T
+		return zero, ErrControllerTimeout
+	}
+}
+
+// call runs an error-only Controller call with the server's timeout.
+func (s *Server) call(fn func() error) error {
+	_, err := callWithTimeout(s.CallTimeout, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// pastDeadline reports whether a deadline is set and has passed.
+func pastDeadline(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
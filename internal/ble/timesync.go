@@ -0,0 +1,48 @@
+package ble
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"tinygo.org/x/bluetooth"
+)
+
+// TimeSync is the JSON form of a Time Sync write, and what the
+// get_system_time RPC returns. The compact write is the same epoch as 8
+// bytes, little-endian.
+type TimeSync struct {
+	Epoch int64 `json:"epoch"` // Unix seconds
+}
+
+var errInvalidTimeSync = errors.New("time sync must be an 8-byte epoch or {\"epoch\": N}")
+
+// parseTimeSync decodes either form of a Time Sync write.
+func parseTimeSync(value []byte) (time.Time, error) {
+	if len(value) == 8 && value[0] != '{' {
+		return time.Unix(int64(binary.LittleEndian.Uint64(value)), 0), nil
+	}
+	var req TimeSync
+	if err := json.Unmarshal(value, &req); err != nil || req.Epoch == 0 {
+		return time.Time{}, errInvalidTimeSync
+	}
+	return time.Unix(req.Epoch, 0), nil
+}
+
+// handleTimeSync sets the device clock. The Controller then reports a
+// recorder state change, whose status notification carries the new time
+// for the client to confirm.
+func (s *Server) handleTimeSync(client bluetooth.Connection, offset int, value []byte) {
+	t, err := parseTimeSync(value)
+	if err != nil {
+		slog.Error("[BLE] Invalid time sync", "err", err)
+		return
+	}
+	if err := s.call(func() error { return s.HW.SetSystemTime(t) }); err != nil {
+		slog.Error("[BLE] Failed to set system time", "time", t, "err", err)
+		return
+	}
+	slog.Info("[BLE] System time synced", "time", t.Format(time.RFC3339))
+}
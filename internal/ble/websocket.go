@@ -0,0 +1,253 @@
+package ble
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal WebSocket server (RFC 6455), enough for the JSON messages of
+// the RPC bridge: no extensions or subprotocols, and messages are read
+// whole up to wsMaxMessage.
+
+// wsGUID is appended to the client's key to prove the handshake was read.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxMessage bounds a message read from a client, as large as an RPC
+// request written over BLE can be.
+const wsMaxMessage = maxFrameSize
+
+// wsWriteTimeout bounds writing one message, so a stalled client can't
+// block the events sent to it.
+const wsWriteTimeout = 10 * time.Second
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+var (
+	errWSHandshake = errors.New("not a websocket handshake")
+	errWSOrigin    = errors.New("websocket origin not allowed")
+	errWSProtocol  = errors.New("websocket protocol error")
+	errWSTooLarge  = errors.New("websocket message too large")
+)
+
+// wsConn is an upgraded connection. Reads are made from one goroutine;
+// writes may come from any.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	writeMu sync.Mutex
+}
+
+// wsUpgrade answers a WebSocket handshake and takes over the connection.
+// On error the client has been sent an HTTP error. Browsers can open a
+// WebSocket from any page, so handshakes from another origin are refused
+// unless it's in allowedOrigins; see wsOriginAllowed.
+func wsUpgrade(w http.ResponseWriter, r *http.Request, allowedOrigins []string) (*wsConn, error) {
+	if !wsOriginAllowed(r, allowedOrigins) {
+		http.Error(w, errWSOrigin.Error(), http.StatusForbidden)
+		return nil, errWSOrigin
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, errWSHandshake.Error(), http.StatusBadRequest)
+		return nil, errWSHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errWSHandshake
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// wsOriginAllowed reports whether a handshake comes from a client that
+// isn't a browser (no Origin), a page served by this host, or an origin
+// listed in allowed, such as "http://localhost:3000".
+func wsOriginAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSuffix(strings.TrimSpace(a), "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// headerHasToken reports whether a comma-separated header lists token,
+// ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for t := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message, answering pings
+// on the way. It returns io.EOF once the client closes the connection.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			// Echo the status code, if any, to complete the closing handshake
+			c.writeFrame(wsClose, payload[:min(len(payload), 2)])
+			return nil, io.EOF
+		case wsText, wsBinary:
+			if started {
+				return nil, errWSProtocol // A new message inside a fragmented one
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				return nil, errWSProtocol
+			}
+		default:
+			return nil, errWSProtocol
+		}
+
+		if len(msg)+len(payload) > wsMaxMessage {
+			return nil, errWSTooLarge
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads and unmasks one frame.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op = head[0]&0x80 != 0, head[0]&0x0F
+	if head[0]&0x70 != 0 || head[1]&0x80 == 0 {
+		return false, 0, nil, errWSProtocol // Reserved bits set, or unmasked
+	}
+
+	size := uint64(head[1] & 0x7F)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= wsClose && (size > 125 || !fin) {
+		return false, 0, nil, errWSProtocol
+	}
+	if size > wsMaxMessage {
+		return false, 0, nil, errWSTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// WriteText sends data as one text message.
+func (c *wsConn) WriteText(data []byte) error {
+	return c.writeFrame(wsText, data)
+}
+
+// Ping checks the client is still there; its pong is dropped by
+// ReadMessage.
+func (c *wsConn) Ping() error {
+	return c.writeFrame(wsPing, nil)
+}
+
+// writeFrame sends one unmasked, final frame.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	frame := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = binary.BigEndian.AppendUint16(append(frame, 126), uint16(n))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, 127), uint64(n))
+	}
+	frame = append(frame, payload...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close drops the connection without a closing handshake.
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
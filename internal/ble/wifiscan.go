@@ -0,0 +1,69 @@
+package ble
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"blueowl-ble/internal/hardware"
+)
+
+// wifiScanTimeout bounds a single Wifi scan.
+const wifiScanTimeout = 30 * time.Second
+
+// streamWifiScan scans for networks, writing each one to the browser
+// characteristic as it's found. Like any browser request, starting a scan
+// aborts the previous one.
+func (s *Server) streamWifiScan() {
+	stream, end := s.beginStream()
+	defer end()
+	ctx, cancel := context.WithTimeout(stream, wifiScanTimeout)
+	defer cancel()
+
+	scan := &wifiScan{cancel: cancel}
+	s.mu.Lock()
+	s.scan = scan
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if s.scan == scan {
+			s.scan = nil
+		}
+		s.mu.Unlock()
+	}()
+
+	err := s.HW.ScanWifi(ctx, func(n hardware.WifiNetwork) {
+		data, _ := json.Marshal(n)
+		s.writeChunked(&s.browserHandle, data)
+	})
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		s.writeChunked(&s.browserHandle, []byte(`{"cancelled": true}`))
+	case err != nil:
+		slog.Error("[BLE] Wifi scan failed", "err", err)
+		s.writeChunked(&s.browserHandle, errorFrame(err))
+	}
+	s.writeChunked(&s.browserHandle, []byte("{}"))
+}
+
+// cancelWifiScan aborts the running scan, if any.
+func (s *Server) cancelWifiScan() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.scan == nil {
+		return false
+	}
+	s.scan.cancel()
+	s.scan = nil
+	return true
+}
+
+// wifiScan identifies the running scan so only its owner clears it.
+type wifiScan struct {
+	cancel context.CancelFunc
+}
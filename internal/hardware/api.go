@@ -1,5 +1,26 @@
 package hardware
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTagQuotaExceeded stops a recording whose tag reached TagQuotaMB.
+var ErrTagQuotaExceeded = errors.New("tag quota exceeded")
+
+// ErrWifiTimeout is returned when ConnectToWifi runs out of time.
+var ErrWifiTimeout = errors.New("wifi connection timed out")
+
+// ErrInsufficientSpace is returned when a recording wouldn't fit on the disk.
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// ErrAlreadyRecording is returned by a start while recording. Of concurrent
+// starts exactly one succeeds; the others fail with it and change nothing.
+var ErrAlreadyRecording = errors.New("already recording")
+
+// Controller abstracts the camera hardware. Long-running methods take a
+// context and return its error if it's cancelled or times out first.
 type Controller interface {
 	// Lifecycle
 	Init() error
@@ -7,20 +28,59 @@ type Controller interface {
 
 	// Wifi Connectivity
 	SetupWifi(ssid, pwd string) error
-	ConnectToWifi() error
+	ConnectToWifi(ctx context.Context) error
 	GetWifiDetails() (*WifiParameters, error)
+	// GetWifiStatus checks the Wifi link as it is now.
+	GetWifiStatus() (*WifiLinkStatus, error)
+	// ScanWifi reports nearby networks through found as they are discovered.
+	ScanWifi(ctx context.Context, found func(WifiNetwork)) error
 
 	// Battery and Storage
 	GetBatteryStatus() (*BatteryStatus, error)
 	GetDiskStatus() (*DiskStatus, error)
+	// GetStorageInfo describes the recordings filesystem. Recording onto a
+	// read-only one fails with ErrStorageReadOnly.
+	GetStorageInfo() (*StorageInfo, error)
 
 	// Camera controls
 	// StartRecorder creates a new videos inside the specified 'folderTag'.
+	// StartRecorderWithOptions also records why and by whom it was started.
 	// e.g. StartRecorder("BestBuyDublin") --> /mnt/sdcard/BestBuyDublin/video_001.mp4
-	StartRecorder(folderTag string) error
+	StartRecorder(ctx context.Context, folderTag string) error
+	StartRecorderWithOptions(ctx context.Context, opts StartOptions) error
 	StopRecorder() error
+	// PauseRecorder suspends the current recording without finalizing it;
+	// ResumeRecorder continues it into the same file.
+	PauseRecorder() error
+	ResumeRecorder() error
 	SetupRecorder(params RecorderParameters) error
 	GetRecorderInfo() (*RecorderParameters, error)
+	GetRecorderLiveStats() (*RecorderLiveStats, error)
+	// GetCurrentSessionID returns the id shared by the chunks of the
+	// recording in progress (RecordingFileInfo.SessionID), "" when idle.
+	// Each start, but not a chunk rollover, begins a new session.
+	GetCurrentSessionID() (string, error)
+	// SetAutoRestart makes a completed chunk roll over into a new recording
+	// under the same tag, and restarts the recorder after a fault.
+	SetAutoRestart(enabled bool) error
+	// PreallocateRecording reserves space for the next recording, failing
+	// with ErrInsufficientSpace rather than running out mid-recording. The
+	// reservation is released when that recording ends.
+	PreallocateRecording(estimatedMB uint64) error
+	// TriggerRecording starts a short recording in a tag derived from the
+	// reason, for event-driven capture. The reason is kept with the video.
+	TriggerRecording(reason string) error
+	// CaptureSnapshot takes a still JPEG, to check the camera's framing
+	// before recording. The camera can't do both at once: it fails with
+	// ErrRecordingInProgress while recording.
+	CaptureSnapshot() ([]byte, error)
+
+	// Schedule: the recorder runs during these daily windows. The schedule
+	// is persisted and replaces any previous one.
+	SetSchedule(windows []RecordingWindow) error
+	GetSchedule() ([]RecordingWindow, error)
+	// GetRecorderEvents returns recent recorder events at or after 'since' (unix secs).
+	GetRecorderEvents(since int64) ([]RecorderEvent, error)
 
 	// Recording filesystem browser
 	// 1. Top Level: Returns how many folders/tags do we have
@@ -33,23 +93,157 @@ type Controller interface {
 	// "fileindex" is a 0-based index within that specific tag/folder.
 	// Returns the file metadata.
 	GetRecordingDetails(tag string, fileIndex uint32) (*RecordingFileInfo, error)
+	// Same, indexing the files in another order. Unknown orders fail with
+	// ErrInvalidSort.
+	GetRecordingDetailsSorted(tag string, fileIndex uint32, by SortBy) (*RecordingFileInfo, error)
+	// Same, finding the file by recording ID, which doesn't shift as files
+	// come and go.
+	GetRecordingByID(tag string, id uint32) (*RecordingFileInfo, error)
+	// A page of files: up to limit (0 for all the rest) from the offset-th,
+	// statting only those. An offset past the end gives an empty page.
+	ListRecordings(tag string, offset, limit uint32) ([]*RecordingFileInfo, error)
+	ListRecordingsSorted(tag string, offset, limit uint32, by SortBy) ([]*RecordingFileInfo, error)
+	// The files grouped into sessions, so the chunks of one recording
+	// can be shown as a single item.
+	ListSessions(tag string) ([]SessionInfo, error)
+
+	// 4. Usage: Total bytes of every file in a tag (videos and sidecars)
+	GetTagDiskUsage(tag string) (uint64, error)
+
+	// 5. Sync: Every recording of a tag with sizes and checksums
+	GetTagManifest(tag string) (*TagManifest, error)
+	ChecksumRecording(tag string, fileIndex uint32) (uint32, error)
+
+	// 6. Gallery: a recording's thumbnail, scaled and re-encoded to keep
+	// transfers small. Bad options fail with ErrInvalidThumbnail.
+	GetThumbnail(tag string, fileIndex uint32, opts ThumbnailOptions) (*Thumbnail, error)
+	// GenerateThumbnail rewrites a recording's thumbnail from its video.
+	// GetThumbnail does so by itself when the thumbnail is missing.
+	GenerateThumbnail(tag string, fileIndex uint32) error
+
+	// Trash
+	// Deleted recordings are kept under RootPath/.trash until emptied.
+	RestoreRecording(id uint32) error
+	EmptyTrash() (uint32, error)
+	// PlanEmptyTrash previews EmptyTrash for dry runs.
+	PlanEmptyTrash() (*DeletePlan, error)
+
+	// Bulk deletion (to trash). The active recording is never deleted.
+	// PlanDelete previews a delete for dry runs: tag "" means all tags,
+	// before 0 means no date bound. Like the deletes, it refuses the tag
+	// being recorded into with ErrTagRecording.
+	PlanDelete(tag string, before int64) (*DeletePlan, error)
+	DeleteRecordingsBefore(unix int64) (uint32, error)
+	DeleteTagRecordings(tag string) (uint32, error)
+	// DeleteRecording trashes a single recording with its sidecars, refusing
+	// the tag being recorded into.
+	DeleteRecording(tag string, id uint32) error
+	// DeleteTag permanently removes a whole tag folder, bypassing the trash,
+	// and returns the bytes freed. Names escaping RootPath fail with
+	// ErrInvalidTag; the tag being recorded into with ErrTagRecording.
+	DeleteTag(tag string) (uint64, error)
+
+	// RepairTag removes zero-byte videos and orphaned sidecars left by a
+	// crash. It refuses the tag being recorded into.
+	RepairTag(tag string) (*RepairReport, error)
+	// PlanRepair previews RepairTag for dry runs.
+	PlanRepair(tag string) (*RepairReport, error)
+
+	// Locale for device-side strings, a language tag such as "en-US".
+	// SetLocale persists it and fails with ErrInvalidLocale on a bad tag.
+	GetLocale() (string, error)
+	SetLocale(locale string) error
+
+	// Clock: headless devices often boot with a wrong time, which would
+	// misname recordings. SetSystemTime fails with ErrInvalidTime for a time
+	// no client could mean.
+	GetSystemTime() (time.Time, error)
+	SetSystemTime(t time.Time) error
+
+	// Config backup: ExportConfig returns a DeviceConfig as JSON, and
+	// ImportConfig restores one, changing nothing unless all of it is valid
+	// (ErrInvalidConfig otherwise).
+	ExportConfig() ([]byte, error)
+	ImportConfig(data []byte) error
+
+	// Diagnostics
+	GetRuntimeStats() (*RuntimeStats, error)
+	// GetDeviceInfo names the board and OS, for the Device Information
+	// service. A StateDevice event tells it changed.
+	GetDeviceInfo() (*DeviceInfo, error)
+	// BenchmarkStorage measures the recordings disk's write and read speed
+	// to catch a slow card before a shoot. It refuses to run while
+	// recording (ErrRecordingInProgress) and takes a few seconds.
+	BenchmarkStorage() (*StorageBenchmark, error)
+	// GetActiveOperations lists long-running tasks (wifi, checksums, bulk
+	// deletes, ...) still in progress.
+	GetActiveOperations() ([]Operation, error)
+	// SubscribeOperations reports operations starting, progressing and
+	// ending through fn until cancel is called.
+	SubscribeOperations(fn func(OperationEvent)) (cancel func())
+
+	// Sensors
+	// StreamIMU sends accelerometer samples of the current recording until
+	// ctx is done or the recording stops, then closes the channel. It's
+	// closed straight away when idle or without a sensor.
+	StreamIMU(ctx context.Context) <-chan IMUSample
+
+	// Events
+	// SubscribeState reports state changes through fn until cancel is
+	// called, so status can be pushed without polling. fn may be called
+	// with the Controller's locks held and must hand off any work that
+	// calls back into the Controller.
+	SubscribeState(fn func(StateEvent)) (cancel func())
 }
 
 type WifiParameters struct {
 	SSID     string `json:"ssid"`
 	Password string `json:"password"`
+
+	// Outcome of the last ConnectToWifi
+	Connected bool   `json:"connected"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Wifi link states
+const (
+	WifiDisconnected = "disconnected"
+	WifiConnecting   = "connecting"
+	WifiConnected    = "connected"
+	WifiFailed       = "failed" // The last connection attempt failed
+)
+
+// WifiLinkStatus is the Wifi link as it is, where WifiParameters only
+// holds the outcome of the last ConnectToWifi.
+type WifiLinkStatus struct {
+	State     string `json:"state"`
+	SSID      string `json:"ssid,omitempty"`       // Network joined or being joined
+	IPAddress string `json:"ip_address,omitempty"` // Once connected
+	RSSI      int8   `json:"rssi,omitempty"`       // dBm once connected
+}
+
+type WifiNetwork struct {
+	SSID     string `json:"ssid"`
+	RSSI     int8   `json:"rssi"`
+	Security string `json:"security"` // e.g. "WPA2", "open"
 }
 
 type BatteryStatus struct {
 	Percentage    uint8  `json:"percentage"`
 	IsCharging    bool   `json:"is_charging"`
-	EstimatedMins uint16 `json:"estimated_mins"`
+	EstimatedMins uint16 `json:"estimated_mins"` // Until empty, or until full while charging
 }
 
 type DiskStatus struct {
 	TotalMB uint32 `json:"total_mb"`
 	UsedMB  uint32 `json:"used_mb"`
 	FreeMB  uint32 `json:"free_mb"`
+	TrashMB uint32 `json:"trash_mb"` // Part of UsedMB, reclaimable via EmptyTrash
+
+	// ReadOnly storage can't be recorded onto (ErrStorageReadOnly), e.g. a
+	// dirty card the kernel mounted read-only. It needs repairing or
+	// replacing.
+	ReadOnly bool `json:"read_only"`
 }
 
 type RecorderParameters struct {
@@ -57,6 +251,43 @@ type RecorderParameters struct {
 	Bitrate     uint32 `json:"bitrate"`
 	ChunkSecs   uint16 `json:"chunk_secs"`
 	FilenameTag string `json:"filename_tag"`
+	TagQuotaMB  uint32 `json:"tag_quota_mb"` // Per-tag size limit, 0 disables
+	MinFreeMB   uint32 `json:"min_free_mb"`  // Stop recording below this much free space, 0 disables
+	AutoRestart bool   `json:"auto_restart"` // Set with SetAutoRestart, ignored by SetupRecorder
+	Paused      bool   `json:"paused"`       // Set with PauseRecorder, ignored by SetupRecorder
+
+	// StartedUnix is when the recording in progress started, by the
+	// device clock and before any chunk rollover. 0 when idle, and ignored
+	// by SetupRecorder.
+	StartedUnix int64 `json:"started_unix,omitempty"`
+
+	// LastError explains why the recorder last stopped on its own
+	LastError string `json:"last_error,omitempty"`
+}
+
+// RecorderLiveStats are measured from the encoder output, as opposed to the
+// configured RecorderParameters. Counters reset when a recording starts.
+type RecorderLiveStats struct {
+	FPS           float32 `json:"fps"`     // 0 when idle
+	Bitrate       uint32  `json:"bitrate"` // 0 when idle
+	DroppedFrames uint32  `json:"dropped_frames"`
+	EncodeErrors  uint32  `json:"encode_errors"`
+}
+
+// StartOptions describe a recording to start. Reason and Operator are
+// stored in the recording's metadata.
+type StartOptions struct {
+	Tag      string `json:"tag"`
+	Reason   string `json:"reason,omitempty"`
+	Operator string `json:"operator,omitempty"`
+
+	// Preallocate reserves bitrate × chunk length before starting (see
+	// PreallocateRecording), or 5 minutes' worth when chunking is off.
+	Preallocate bool `json:"preallocate,omitempty"`
+
+	// Config overrides the non-zero FPS, Bitrate and ChunkSecs for this
+	// recording only; the configured values are restored when it stops.
+	Config *RecorderParameters `json:"config,omitempty"`
 }
 
 type TagInfo struct {
@@ -65,10 +296,45 @@ type TagInfo struct {
 }
 
 type RecordingFileInfo struct {
-	ID            uint16 `json:"id"`
+	ID            uint32 `json:"id"`
 	FileName      string `json:"filename"`
 	Path          string `json:"path"`
 	SizeMB        uint32 `json:"size_mb"`
 	IMUFilePath   string `json:"imu_filepath"`
 	ThumbnailPath string `json:"thumbnail_path"`
+	InProgress    bool   `json:"in_progress,omitempty"` // Still being recorded
+	Reason        string `json:"reason,omitempty"`
+	Operator      string `json:"operator,omitempty"`
+	SessionID     string `json:"session_id,omitempty"` // Groups the chunks of a recording, see ListSessions
+	Checksum      uint32 `json:"crc32,omitempty"`      // Cached CRC-32, 0 until first computed
+	ModifiedUnix  int64  `json:"modified_unix"`
+}
+
+// SortBy orders the files of a tag.
+type SortBy string
+
+const (
+	SortByName SortBy = "name" // Alphabetical, the default
+	SortByTime SortBy = "time" // Oldest modification first
+)
+
+// ErrInvalidSort is returned for a SortBy other than the above.
+var ErrInvalidSort = errors.New("invalid sort order")
+
+// Valid reports whether by is a known order, "" meaning SortByName.
+func (by SortBy) Valid() bool {
+	return by == "" || by == SortByName || by == SortByTime
+}
+
+type RuntimeStats struct {
+	UptimeSecs         uint32 `json:"uptime_secs"`
+	RecorderUptimeSecs uint32 `json:"recorder_uptime_secs"` // Current recording, 0 when idle
+	RecordingsCreated  uint32 `json:"recordings_created"`   // Since Init
+	DiskReads          uint32 `json:"disk_reads"`
+	LastDiskReadUs     uint32 `json:"last_disk_read_us"`
+	AvgDiskReadUs      uint32 `json:"avg_disk_read_us"` // Over the last 16 reads
+
+	// Last BenchmarkStorage result, 0 until one has run
+	StorageWriteMBps float32 `json:"storage_write_mbps"`
+	StorageReadMBps  float32 `json:"storage_read_mbps"`
 }
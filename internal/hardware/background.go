@@ -0,0 +1,81 @@
+package hardware
+
+import (
+	"sync"
+	"time"
+)
+
+// trashPurgeInterval is how often expired trash is purged in the background.
+const trashPurgeInterval = time.Hour
+
+// background tracks goroutines doing maintenance for a FileBrowser so Close
+// can stop them. The zero value is ready to use.
+type background struct {
+	mu     sync.Mutex
+	done   chan struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// run starts fn in a goroutine; fn must return once done is closed.
+// Nothing is started after close.
+func (b *background) run(fn func(done <-chan struct{})) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	if b.done == nil {
+		b.done = make(chan struct{})
+	}
+	b.wg.Add(1)
+	go func(done <-chan struct{}) {
+		defer b.wg.Done()
+		fn(done)
+	}(b.done)
+}
+
+// close signals every goroutine to stop and waits for them.
+func (b *background) close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	if b.done != nil {
+		close(b.done)
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// Start runs the browser's background maintenance: purging expired trash
+// now and then periodically, when TrashRetention is set.
+func (fb *FileBrowser) Start() {
+	fb.purgeExpiredTrash()
+	if fb.TrashRetention <= 0 {
+		return
+	}
+
+	fb.bg.run(func(done <-chan struct{}) {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				fb.purgeExpiredTrash()
+			}
+		}
+	})
+}
+
+// Close stops the background maintenance and waits for it to finish. It is
+// safe to call more than once; the browser still serves reads afterwards.
+func (fb *FileBrowser) Close() {
+	fb.bg.close()
+}
@@ -0,0 +1,88 @@
+package hardware
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// benchmarkSizeMB is written and read back by a storage benchmark.
+const benchmarkSizeMB = 32
+
+// benchmarkFile is the scratch file of a benchmark, in SettingsDir.
+const benchmarkFile = "benchmark.tmp"
+
+// StorageBenchmark is the measured throughput of the recordings disk.
+type StorageBenchmark struct {
+	WriteMBps float32 `json:"write_mbps"`
+	ReadMBps  float32 `json:"read_mbps"` // May be flattered by the page cache
+	SizeMB    uint32  `json:"size_mb"`
+	Unix      int64   `json:"unix"`
+	Simulated bool    `json:"simulated,omitempty"`
+}
+
+// benchmarkStorage times writing a scratch file under RootPath, synced to
+// the card, and reading it back. The file is removed afterwards.
+func (fb *FileBrowser) benchmarkStorage() (*StorageBenchmark, error) {
+	op := fb.ops.Begin(OpBenchmark)
+	defer op.End()
+
+	dir := filepath.Join(fb.RootPath, SettingsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, benchmarkFile)
+	defer os.Remove(path)
+
+	buf := make([]byte, 1024*1024)
+	for i := range buf {
+		buf[i] = byte(i) // Not zeros, which some controllers compress
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	for i := range benchmarkSizeMB {
+		op.SetProgress(i, 2*benchmarkSizeMB)
+		if _, err := f.Write(buf); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	writeTime := time.Since(start)
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	op.SetProgress(benchmarkSizeMB, 2*benchmarkSizeMB)
+	start = time.Now()
+	if _, err := io.CopyBuffer(io.Discard, f, buf); err != nil {
+		return nil, err
+	}
+	readTime := time.Since(start)
+
+	b := &StorageBenchmark{
+		WriteMBps: mbPerSec(benchmarkSizeMB, writeTime),
+		ReadMBps:  mbPerSec(benchmarkSizeMB, readTime),
+		SizeMB:    benchmarkSizeMB,
+		Unix:      time.Now().Unix(),
+	}
+	fb.io.recordBenchmark(b)
+	return b, nil
+}
+
+func mbPerSec(mb int, d time.Duration) float32 {
+	return float32(float64(mb) / max(d.Seconds(), 1e-6))
+}
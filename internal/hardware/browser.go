@@ -1,6 +1,7 @@
 package hardware
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"hash/crc32"
@@ -8,87 +9,164 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// ErrInvalidTag is returned for a tag name that could reach outside its
+// folder under RootPath.
+var ErrInvalidTag = errors.New("invalid tag name")
+
+// ErrRecordingNotFound is returned when no recording of a tag has an ID.
+var ErrRecordingNotFound = errors.New("recording not found")
+
+// ErrRootNotDirectory is returned when RootPath exists but isn't a
+// directory, such as a misconfigured path to a file.
+var ErrRootNotDirectory = errors.New("root path is not a directory")
+
+// DefaultMaxDepth bounds recursive tag discovery when MaxDepth is unset.
+const DefaultMaxDepth = 4
+
+// DefaultIgnoreNames are system folders removable media tend to carry.
+var DefaultIgnoreNames = []string{"System Volume Information", "$RECYCLE.BIN", "lost+found"}
+
 // FileBrowser handles the logic for reading the disk.
 type FileBrowser struct {
 	RootPath string // e.g. /tmp or /mnt/sdcard
+
+	// FollowSymlinks treats symlinks to directories as tags.
+	// When false they are skipped.
+	FollowSymlinks bool
+
+	// ShowHidden lists dot-prefixed tags and files (.Trashes, ._vid.mp4, ...).
+	ShowHidden bool
+	// IgnoreNames are tag/file names never listed. Nil means DefaultIgnoreNames.
+	IgnoreNames []string
+
+	// RequireRecordings only treats folders holding at least one .mp4 as tags,
+	// keeping the tag count consistent with what the files view can show.
+	RequireRecordings bool
+
+	// Recursive discovers tags at any depth below RootPath (e.g. "site/day").
+	// A nested folder is a tag when it directly holds recordings, and its
+	// name is the slash-separated path relative to RootPath.
+	Recursive bool
+	MaxDepth  int // 0 means DefaultMaxDepth
+
+	// TrashRetention auto-empties trash batches older than this. 0 keeps
+	// them until EmptyTrash is called.
+	TrashRetention time.Duration
+
+	io  ioStats
+	bg  background // See Start and Close
+	ops Operations // Running background tasks, see GetActiveOperations
+
+	// Re-encoded thumbnails, see GetThumbnail
+	thumbs thumbnailCache
+	// Writes a video's thumbnail sidecar, see GenerateThumbnail
+	makeThumbnail func(videoPath, thumbPath string) error
+
+	// State changes, see SubscribeState
+	state EventBus[StateEvent]
+
+	// Video being recorded, listed as in progress
+	active atomic.Pointer[activeRecording]
+
+	// Deduplicate concurrent listings of the same folder
+	tagWalks  flightGroup[[]string]
+	fileWalks flightGroup[[]os.DirEntry]
+}
+
+// EnsureRoot checks RootPath is a directory, creating it if it's missing.
+// Anything else in its place is left alone: ErrRootNotDirectory.
+func (fb *FileBrowser) EnsureRoot() error {
+	info, err := os.Stat(fb.RootPath)
+	switch {
+	case err == nil && !info.IsDir():
+		return fmt.Errorf("%w: %s", ErrRootNotDirectory, fb.RootPath)
+	case err == nil:
+		return nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	slog.Error("Folder does not exist, creating it", "path", fb.RootPath)
+	if err := os.MkdirAll(fb.RootPath, 0755); err != nil {
+		slog.Error("Failed to create root directory", "err", err)
+		return err
+	}
+	return nil
 }
 
 // GetNumOfTags: Count sub-directories in RootPath
 func (fb *FileBrowser) GetNumOfTags() (uint32, error) {
-	entries, err := os.ReadDir(fb.RootPath)
+	tags, err := fb.getSortedTags()
 	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			slog.Error("Folder does not exist, creating it", "path", fb.RootPath)
-			if err := os.MkdirAll(fb.RootPath, 0755); err != nil {
-				slog.Error("Failed to create root directory", "err", err)
-				return 0, err
-			}
-			return 0, nil
-		}
-
 		if errors.Is(err, fs.ErrPermission) {
 			slog.Error("Insufficient permissions to open folder", "path", fb.RootPath)
 			return 0, err
 		}
 
+		if rootErr := fb.EnsureRoot(); rootErr != nil {
+			return 0, rootErr
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil // Created empty by EnsureRoot
+		}
 		return 0, err
 	}
 
-	count := 0
-	for _, e := range entries {
-		if e.IsDir() {
-			// TODO: Maybe filter for some sort of flag in folder or check for videos.
-			count++
-		}
-	}
-	return uint32(count), nil
+	return uint32(len(tags)), nil
 }
 
 // GetTagInfoByIndex: Return info for the Nth folder (Alphabetical)
 func (fb *FileBrowser) GetTagInfoByIndex(idx uint32) (*TagInfo, error) {
-	dirs, err := fb.getSortedDirs()
+	tags, err := fb.getSortedTags()
 	if err != nil {
 		return nil, err
 	}
 
-	if int(idx) >= len(dirs) {
-		return nil, fmt.Errorf("tag index %d out of bounds (count: %d)", idx, len(dirs))
+	if int(idx) >= len(tags) {
+		return nil, fmt.Errorf("tag index %d out of bounds (count: %d)", idx, len(tags))
 	}
 
-	dirEntry := dirs[idx]
-	tagName := dirEntry.Name()
-	fullPath := filepath.Join(fb.RootPath, tagName)
+	tagName := tags[idx]
+	fullPath := fb.tagPath(tagName)
 
 	// Count files inside the this tag (only .mp4)
-	files, err := os.ReadDir(fullPath)
+	files, err := fb.getSortedFiles(fullPath)
 	if err != nil {
 		slog.Error("failed to get tag directory", "tag", tagName, "err", err)
 		return nil, err
 	}
 
-	fileCount := 0
-	for _, f := range files {
-		if !f.IsDir() && strings.HasSuffix(f.Name(), ".mp4") {
-			fileCount++
-		}
-	}
-
 	return &TagInfo{
 		Name:            tagName,
-		NumOfRecordings: uint32(fileCount),
+		NumOfRecordings: uint32(len(files)),
 	}, nil
 
 }
 
 // GetRecordingDetails: Return info for the Nth file in a tag (Alphabetical)
 func (fb *FileBrowser) GetRecordingDetails(tag string, fileIndex uint32) (*RecordingFileInfo, error) {
-	tagPath := filepath.Join(fb.RootPath, tag)
+	return fb.GetRecordingDetailsSorted(tag, fileIndex, SortByName)
+}
 
-	files, err := fb.getSortedFiles(tagPath)
+// GetRecordingDetailsSorted: Return info for the Nth file in a tag, in the
+// given order
+func (fb *FileBrowser) GetRecordingDetailsSorted(tag string, fileIndex uint32, by SortBy) (*RecordingFileInfo, error) {
+	if !by.Valid() {
+		return nil, fmt.Errorf("%w %q", ErrInvalidSort, by)
+	}
+	if err := fb.validateTag(tag); err != nil {
+		return nil, err
+	}
+	tagPath := fb.tagPath(tag)
+
+	files, err := fb.getFilesBy(tagPath, by)
 	if err != nil {
 		return nil, fmt.Errorf("tag '%s' not found or empty", tag)
 	}
@@ -96,52 +174,385 @@ func (fb *FileBrowser) GetRecordingDetails(tag string, fileIndex uint32) (*Recor
 	if int(fileIndex) >= len(files) {
 		return nil, fmt.Errorf("files index %d out of bounds", fileIndex)
 	}
+	return fb.fileInfo(tagPath, files[fileIndex])
+}
 
-	f := files[fileIndex]
+// ListRecordings: Return up to limit files of a tag from the offset-th
+// (Alphabetical), or all the rest when limit is 0
+func (fb *FileBrowser) ListRecordings(tag string, offset, limit uint32) ([]*RecordingFileInfo, error) {
+	return fb.ListRecordingsSorted(tag, offset, limit, SortByName)
+}
+
+// ListRecordingsSorted: Same, in the given order. The folder is read once
+// per page; reading it still stats every finished video to drop the empty
+// ones, so the listing keeps the indexes the other lookups use.
+func (fb *FileBrowser) ListRecordingsSorted(tag string, offset, limit uint32, by SortBy) ([]*RecordingFileInfo, error) {
+	if !by.Valid() {
+		return nil, fmt.Errorf("%w %q", ErrInvalidSort, by)
+	}
+	if err := fb.validateTag(tag); err != nil {
+		return nil, err
+	}
+	tagPath := fb.tagPath(tag)
+
+	files, err := fb.getFilesBy(tagPath, by)
+	if err != nil {
+		return nil, fmt.Errorf("tag '%s' not found or empty", tag)
+	}
+
+	if int(offset) >= len(files) {
+		return nil, nil
+	}
+	files = files[offset:]
+	if limit > 0 && int(limit) < len(files) {
+		files = files[:limit]
+	}
+
+	list := make([]*RecordingFileInfo, 0, len(files))
+	for _, f := range files {
+		info, err := fb.fileInfo(tagPath, f)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // Deleted since the folder was read
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+// GetRecordingByID: Return info for the file of a tag with the given ID,
+// which unlike an index doesn't shift as files come and go
+func (fb *FileBrowser) GetRecordingByID(tag string, id uint32) (*RecordingFileInfo, error) {
+	if err := fb.validateTag(tag); err != nil {
+		return nil, err
+	}
+	tagPath := fb.tagPath(tag)
+
+	files, err := fb.getSortedFiles(tagPath)
+	if err != nil {
+		return nil, fmt.Errorf("tag '%s' not found or empty", tag)
+	}
+
+	for _, f := range files {
+		if recordingID(f.Name()) == id {
+			return fb.fileInfo(tagPath, f)
+		}
+	}
+	return nil, fmt.Errorf("%w: id %d in tag '%s'", ErrRecordingNotFound, id, tag)
+}
+
+// fileInfo describes a recording of the tag folder at tagPath.
+func (fb *FileBrowser) fileInfo(tagPath string, f os.DirEntry) (*RecordingFileInfo, error) {
 	info, err := f.Info()
 	if err != nil {
 		return nil, err
 	}
 
-	absPath, _ := filepath.Abs(filepath.Join(tagPath, f.Name()))
+	fullPath := filepath.Join(tagPath, f.Name())
+	absPath, _ := filepath.Abs(fullPath)
 
-	// Generate a consistent ID (CRC32 of filename)
-	id := uint16(crc32.ChecksumIEEE([]byte(f.Name())))
+	md, err := readMetadata(fullPath)
+	if err != nil {
+		slog.Warn("unreadable recording metadata", "file", fullPath, "err", err)
+	}
+	checksum, _ := md.cachedCRC32(info) // Only computed on demand
 
 	return &RecordingFileInfo{
-		ID:       id,
+		ID:       recordingID(f.Name()),
 		FileName: f.Name(),
 		Path:     absPath,
 		SizeMB:   uint32(info.Size() / 1024 / 1024),
 		// Assumptions
 		IMUFilePath:   strings.Replace(absPath, ".mp4", ".imu", 1),
 		ThumbnailPath: strings.Replace(absPath, ".mp4", ".jpg", 1),
+		InProgress:    fb.isActive(fullPath),
+		Reason:        md.Reason,
+		Operator:      md.Operator,
+		SessionID:     cmp.Or(md.SessionID, fb.activeSession(fullPath)),
+		Checksum:      checksum,
+		ModifiedUnix:  info.ModTime().Unix(),
 	}, nil
 }
 
-func (fb *FileBrowser) getSortedDirs() ([]os.DirEntry, error) {
-	entries, err := os.ReadDir(fb.RootPath)
+// GetTagDiskUsage: Return the bytes used by all files in a tag
+func (fb *FileBrowser) GetTagDiskUsage(tag string) (uint64, error) {
+	if err := fb.validateTag(tag); err != nil {
+		return 0, err
+	}
+	if _, err := os.Stat(fb.tagPath(tag)); err != nil {
+		return 0, fmt.Errorf("tag '%s' not found", tag)
+	}
+	return fb.tagSizeBytes(tag)
+}
+
+// tagSizeBytes sums the size of every file directly inside a tag folder.
+// A missing tag is empty.
+func (fb *FileBrowser) tagSizeBytes(tag string) (uint64, error) {
+	entries, err := fb.readDir(fb.tagPath(tag))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var total uint64
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			if info, err := e.Info(); err == nil {
+				total += uint64(info.Size())
+			}
+		}
+	}
+	return total, nil
+}
+
+// recordingID generates a consistent ID (CRC32 of filename).
+func recordingID(fileName string) uint32 {
+	return crc32.ChecksumIEEE([]byte(fileName))
+}
+
+// getSortedTags returns tag names sorted alphabetically. Concurrent callers
+// share a single walk.
+func (fb *FileBrowser) getSortedTags() ([]string, error) {
+	return fb.tagWalks.Do(fb.RootPath, fb.walkSortedTags)
+}
+
+func (fb *FileBrowser) walkSortedTags() ([]string, error) {
+	entries, err := fb.readDir(fb.RootPath)
 	if err != nil {
 		return nil, err
 	}
 
+	var tags []string
+	seen := make(map[string]bool)
+
+	if fb.Recursive {
+		for _, d := range fb.subDirs(fb.RootPath, entries, seen) {
+			tags = append(tags, fb.walkTags(d.Name(), 1, seen)...)
+		}
+	} else {
+		for _, d := range fb.subDirs(fb.RootPath, entries, seen) {
+			if fb.RequireRecordings && !fb.hasRecordings(fb.tagPath(d.Name())) {
+				continue
+			}
+			tags = append(tags, d.Name())
+		}
+	}
+
+	// Sort alphabetically by name
+	sort.Strings(tags)
+
+	return tags, nil
+}
+
+// walkTags collects the tags at or below rel, descending at most MaxDepth levels.
+func (fb *FileBrowser) walkTags(rel string, depth int, seen map[string]bool) []string {
+	dir := fb.tagPath(rel)
+	entries, err := fb.readDir(dir)
+	if err != nil {
+		slog.Warn("Failed to read nested tag folder", "path", dir, "err", err)
+		return nil
+	}
+
+	var tags []string
+	if slices.ContainsFunc(entries, fb.isRecording) {
+		tags = append(tags, rel)
+	}
+
+	maxDepth := fb.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if depth >= maxDepth {
+		return tags
+	}
+
+	for _, d := range fb.subDirs(dir, entries, seen) {
+		tags = append(tags, fb.walkTags(rel+"/"+d.Name(), depth+1, seen)...)
+	}
+	return tags
+}
+
+// subDirs filters the entries of dir down to the folders worth listing.
+// seen holds resolved symlink targets so each linked folder is visited once.
+func (fb *FileBrowser) subDirs(dir string, entries []os.DirEntry, seen map[string]bool) []os.DirEntry {
 	var dirs []os.DirEntry
+
 	for _, e := range entries {
-		if e.IsDir() {
-			dirs = append(dirs, e)
+		if fb.ignored(e.Name()) {
+			continue
+		}
+
+		if e.Type()&fs.ModeSymlink != 0 {
+			if !fb.FollowSymlinks {
+				slog.Debug("Skipping symlinked tag", "name", e.Name())
+				continue
+			}
+			target, ok := fb.resolveTagLink(filepath.Join(dir, e.Name()))
+			if !ok || seen[target] {
+				continue
+			}
+			seen[target] = true
+		} else if !e.IsDir() {
+			continue
 		}
+
+		dirs = append(dirs, e)
 	}
+	return dirs
+}
 
-	// Sort alphabetically by name
-	sort.Slice(dirs, func(i, j int) bool {
-		return dirs[i].Name() < dirs[j].Name()
-	})
+// tagPath returns the folder for a (possibly slash-separated) tag name.
+// validateTag rejects tag names that could escape RootPath or reach the
+// trash and settings folders: empty, absolute or backslashed names, "." and
+// ".." parts, and nesting unless Recursive (where "/" separates parts).
+// Every exported method taking a tag checks it before using tagPath.
+func (fb *FileBrowser) validateTag(tag string) error {
+	if tag == "" || strings.HasPrefix(tag, "/") || strings.Contains(tag, `\`) ||
+		filepath.IsAbs(tag) || filepath.VolumeName(tag) != "" {
+		return fmt.Errorf("%w %q", ErrInvalidTag, tag)
+	}
+
+	parts := strings.Split(tag, "/")
+	if len(parts) > 1 && !fb.Recursive {
+		return fmt.Errorf("%w %q", ErrInvalidTag, tag)
+	}
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." {
+			return fmt.Errorf("%w %q", ErrInvalidTag, tag)
+		}
+	}
+	if parts[0] == TrashDir || parts[0] == SettingsDir {
+		return fmt.Errorf("%w %q", ErrInvalidTag, tag)
+	}
+	return nil
+}
+
+func (fb *FileBrowser) tagPath(tag string) string {
+	return filepath.Join(fb.RootPath, filepath.FromSlash(tag))
+}
+
+// hasRecordings reports whether a folder holds at least one listable .mp4.
+func (fb *FileBrowser) hasRecordings(path string) bool {
+	entries, err := fb.readDir(path)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(entries, fb.isRecording)
+}
+
+// isRecording reports whether an entry is a listable video file.
+func (fb *FileBrowser) isRecording(e os.DirEntry) bool {
+	return !e.IsDir() && strings.HasSuffix(e.Name(), ".mp4") && !fb.ignored(e.Name())
+}
+
+// activeRecording is the video being written and the session it's part of,
+// which its metadata only records once it's finalized.
+type activeRecording struct {
+	path    string
+	session string
+}
+
+// setActiveRecording marks the video being written, "" when idle.
+func (fb *FileBrowser) setActiveRecording(path, session string) {
+	if path == "" {
+		fb.active.Store(nil)
+		return
+	}
+	fb.active.Store(&activeRecording{path: path, session: session})
+}
+
+// isActive reports whether path is the video being recorded.
+func (fb *FileBrowser) isActive(path string) bool {
+	active := fb.active.Load()
+	return active != nil && active.path == path
+}
+
+// activeSession returns the session of path if it's the video being
+// recorded, "" otherwise.
+func (fb *FileBrowser) activeSession(path string) string {
+	if active := fb.active.Load(); active != nil && active.path == path {
+		return active.session
+	}
+	return ""
+}
+
+// ignored reports whether a tag or file name is filtered from listings.
+func (fb *FileBrowser) ignored(name string) bool {
+	if name == TrashDir || name == SettingsDir {
+		return true
+	}
+	if !fb.ShowHidden && strings.HasPrefix(name, ".") {
+		return true
+	}
+
+	names := fb.IgnoreNames
+	if names == nil {
+		names = DefaultIgnoreNames
+	}
+	return slices.Contains(names, name)
+}
+
+// resolveTagLink returns the real path of a symlinked tag if it points to a
+// directory that doesn't contain RootPath (which would loop back on itself).
+func (fb *FileBrowser) resolveTagLink(name string) (string, bool) {
+	target, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		slog.Warn("Ignoring broken tag symlink", "name", name, "err", err)
+		return "", false
+	}
+
+	info, err := os.Stat(target)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+
+	root, err := filepath.EvalSymlinks(fb.RootPath)
+	if err != nil {
+		return "", false
+	}
+	if rel, err := filepath.Rel(target, root); err == nil && !strings.HasPrefix(rel, "..") {
+		slog.Warn("Ignoring tag symlink that loops back to root", "name", name, "target", target)
+		return "", false
+	}
 
-	return dirs, nil
+	return target, true
 }
 
+// getSortedFiles returns the recordings in a folder sorted alphabetically.
+// Concurrent callers for the same folder share a single read.
 func (fb *FileBrowser) getSortedFiles(path string) ([]os.DirEntry, error) {
-	entries, err := os.ReadDir(path)
+	return fb.fileWalks.Do(path, func() ([]os.DirEntry, error) {
+		return fb.readSortedFiles(path)
+	})
+}
+
+// getFilesBy returns the recordings in a folder in the given order.
+func (fb *FileBrowser) getFilesBy(path string, by SortBy) ([]os.DirEntry, error) {
+	files, err := fb.getSortedFiles(path)
+	if err != nil || by != SortByTime {
+		return files, err
+	}
+
+	// Oldest first, by name when equal. The shared listing isn't reordered.
+	mtimes := make(map[string]int64, len(files))
+	for _, f := range files {
+		if info, err := f.Info(); err == nil {
+			mtimes[f.Name()] = info.ModTime().UnixNano()
+		}
+	}
+	files = slices.Clone(files)
+	slices.SortStableFunc(files, func(a, b os.DirEntry) int {
+		return cmp.Compare(mtimes[a.Name()], mtimes[b.Name()])
+	})
+	return files, nil
+}
+
+func (fb *FileBrowser) readSortedFiles(path string) ([]os.DirEntry, error) {
+	entries, err := fb.readDir(path)
 	if err != nil {
 		return nil, err
 	}
@@ -149,13 +560,26 @@ func (fb *FileBrowser) getSortedFiles(path string) ([]os.DirEntry, error) {
 	var files []os.DirEntry
 	for _, e := range entries {
 		// Filter: Must be file AND end in .mp4
-		if !e.IsDir() && strings.HasSuffix(e.Name(), ".mp4") {
-			files = append(files, e)
+		if !fb.isRecording(e) {
+			continue
+		}
+		// A crash can leave an empty video behind. The one being recorded
+		// is kept and flagged in progress instead.
+		if !fb.isActive(filepath.Join(path, e.Name())) {
+			if info, err := e.Info(); err != nil || info.Size() == 0 {
+				continue
+			}
 		}
+		files = append(files, e)
 	}
 
-	// Sort alphabetically by name
+	// Sort alphabetically by name, undated names from older firmware first
+	// (they'd otherwise interleave with dated ones by hour)
 	sort.Slice(files, func(i, j int) bool {
+		li, lj := isUndatedName(files[i].Name()), isUndatedName(files[j].Name())
+		if li != lj {
+			return li
+		}
 		return files[i].Name() < files[j].Name()
 	})
 
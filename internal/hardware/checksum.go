@@ -0,0 +1,87 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// ErrRecordingInProgress is returned when checksumming a video still being
+// written.
+var ErrRecordingInProgress = errors.New("recording is in progress")
+
+// ChecksumRecording returns the CRC-32 of the Nth video in a tag. Large
+// videos take a while the first time; later calls use the cached value.
+func (fb *FileBrowser) ChecksumRecording(tag string, fileIndex uint32) (uint32, error) {
+	if err := fb.validateTag(tag); err != nil {
+		return 0, err
+	}
+	tagPath := fb.tagPath(tag)
+	files, err := fb.getSortedFiles(tagPath)
+	if err != nil {
+		return 0, fmt.Errorf("tag '%s' not found or empty", tag)
+	}
+	if int(fileIndex) >= len(files) {
+		return 0, fmt.Errorf("files index %d out of bounds", fileIndex)
+	}
+
+	f := files[fileIndex]
+	path := filepath.Join(tagPath, f.Name())
+	if fb.isActive(path) {
+		return 0, ErrRecordingInProgress
+	}
+	info, err := f.Info()
+	if err != nil {
+		return 0, err
+	}
+
+	op := fb.ops.Begin(OpChecksum)
+	defer op.End()
+	return checksum(path, info)
+}
+
+// checksum returns the CRC-32 of a finished video. The result is cached in
+// the video's metadata sidecar and reused until the file changes.
+func checksum(path string, info fs.FileInfo) (uint32, error) {
+	md, mdErr := readMetadata(path)
+	if sum, ok := md.cachedCRC32(info); ok && mdErr == nil {
+		return sum, nil
+	}
+
+	sum, err := fileCRC32(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// Never overwrite a sidecar we couldn't read
+	if mdErr == nil {
+		md.CRC32 = sum
+		md.CRC32Size = info.Size()
+		md.CRC32ModNano = info.ModTime().UnixNano()
+		if err := writeMetadata(path, md); err != nil {
+			slog.Warn("Failed to cache checksum", "file", path, "err", err)
+		}
+	}
+	return sum, nil
+}
+
+// fileCRC32 computes the IEEE CRC-32 of a file. It streams the file through
+// a small buffer, so memory use doesn't grow with the file size.
+func fileCRC32(path string) (uint32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
@@ -0,0 +1,26 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Clock tells the time. Tests inject one to drive time-based features.
+type Clock interface {
+	Now() time.Time
+}
+
+// ErrInvalidTime is returned by SetSystemTime for an implausible time.
+var ErrInvalidTime = errors.New("invalid system time")
+
+// minSystemTime is older than any real date a client can send. Earlier
+// times are a client bug (an unset clock, seconds read as ms, ...).
+var minSystemTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func validateSystemTime(t time.Time) error {
+	if t.Before(minSystemTime) {
+		return fmt.Errorf("%w: %s is before %s", ErrInvalidTime, t.UTC().Format(time.RFC3339), minSystemTime.Format("2006"))
+	}
+	return nil
+}
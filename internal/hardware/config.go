@@ -0,0 +1,105 @@
+package hardware
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ConfigVersion is the format of configs written by ExportConfig.
+const ConfigVersion = 1
+
+// ErrInvalidConfig is returned by ImportConfig for a config it can't apply.
+// Nothing is changed when it's returned.
+var ErrInvalidConfig = errors.New("invalid config")
+
+// DeviceConfig is everything ExportConfig saves, to back up a device or
+// clone its setup onto another.
+type DeviceConfig struct {
+	Version  int               `json:"version"`
+	Recorder RecorderDefaults  `json:"recorder"`
+	Schedule []RecordingWindow `json:"schedule"`
+	Locale   string            `json:"locale"`
+
+	// WifiSSID is exported for reference only: without the password (which
+	// is never exported) importing leaves the Wifi credentials alone.
+	WifiSSID string `json:"wifi_ssid,omitempty"`
+}
+
+// RecorderDefaults are the RecorderParameters worth carrying to another
+// device, without the state of the current recording.
+type RecorderDefaults struct {
+	FPS         uint8  `json:"fps"`
+	Bitrate     uint32 `json:"bitrate"`
+	ChunkSecs   uint16 `json:"chunk_secs"`
+	TagQuotaMB  uint32 `json:"tag_quota_mb"`
+	MinFreeMB   uint32 `json:"min_free_mb"`
+	AutoRestart bool   `json:"auto_restart"`
+}
+
+func recorderDefaults(p RecorderParameters, autoRestart bool) RecorderDefaults {
+	return RecorderDefaults{
+		FPS:         p.FPS,
+		Bitrate:     p.Bitrate,
+		ChunkSecs:   p.ChunkSecs,
+		TagQuotaMB:  p.TagQuotaMB,
+		MinFreeMB:   p.MinFreeMB,
+		AutoRestart: autoRestart,
+	}
+}
+
+// apply copies the defaults into p, leaving its recording state alone.
+func (d RecorderDefaults) apply(p *RecorderParameters) {
+	p.FPS = d.FPS
+	p.Bitrate = d.Bitrate
+	p.ChunkSecs = d.ChunkSecs
+	p.TagQuotaMB = d.TagQuotaMB
+	p.MinFreeMB = d.MinFreeMB
+}
+
+// withOverride applies the non-zero settings of a per-recording config
+// over base.
+func withOverride(base, c RecorderParameters) RecorderParameters {
+	base.FPS = cmp.Or(c.FPS, base.FPS)
+	base.Bitrate = cmp.Or(c.Bitrate, base.Bitrate)
+	base.ChunkSecs = cmp.Or(c.ChunkSecs, base.ChunkSecs)
+	return base
+}
+
+// marshalConfig encodes an export, listing an empty schedule as [].
+func marshalConfig(c DeviceConfig) ([]byte, error) {
+	c.Version = ConfigVersion
+	if c.Schedule == nil {
+		c.Schedule = []RecordingWindow{}
+	}
+	return json.Marshal(c)
+}
+
+// parseConfig decodes and validates an import, so it can then be applied
+// without failing halfway. The locale comes back canonical.
+func parseConfig(data []byte) (*DeviceConfig, error) {
+	var c DeviceConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	if c.Version != ConfigVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidConfig, c.Version)
+	}
+	if c.Recorder.FPS == 0 || c.Recorder.Bitrate == 0 {
+		return nil, fmt.Errorf("%w: recorder fps and bitrate are required", ErrInvalidConfig)
+	}
+	if err := validateSchedule(c.Schedule); err != nil {
+		return nil, fmt.Errorf("%w: schedule: %v", ErrInvalidConfig, err)
+	}
+	locale, err := canonicalLocale(c.Locale)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	c.Locale = locale
+	return &c, nil
+}
@@ -0,0 +1,57 @@
+package hardware
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Environment variables read by NewController
+const (
+	// RootEnv overrides where recordings are kept.
+	RootEnv = "BLUEOWL_ROOT"
+	// HWEnv selects the hardware: "pi" for the Raspberry Pi, anything else
+	// for the mock.
+	HWEnv = "BLUEOWL_HW"
+)
+
+// Hardware backends, see Backend
+const (
+	BackendMock = "mock"
+	BackendPi   = "pi"
+)
+
+// Backend names the hardware c drives, and where it keeps recordings.
+func Backend(c Controller) (name, root string) {
+	if b, ok := c.(interface{ backend() (string, string) }); ok {
+		return b.backend()
+	}
+	return "unknown", ""
+}
+
+// NewController returns the Controller selected by $BLUEOWL_HW. The Pi
+// records to $BLUEOWL_ROOT or else DefaultPiRoot; the mock to $BLUEOWL_ROOT
+// or else ./test_recordings. If the Pi was asked for and can't start, it
+// returns the error rather than fall back to a mock writing fake
+// recordings onto the card.
+func NewController() (Controller, error) {
+	root := os.Getenv(RootEnv)
+
+	if os.Getenv(HWEnv) == BackendPi {
+		if root == "" {
+			root = DefaultPiRoot
+		}
+		pi, err := newPiController(root)
+		if err != nil {
+			return nil, fmt.Errorf("pi hardware unavailable: %w", err)
+		}
+		return pi, nil
+	}
+
+	if root == "" {
+		// Setup a local folder for testing
+		cwd, _ := os.Getwd()
+		root = filepath.Join(cwd, "test_recordings")
+	}
+	return NewMockController(MockOptions{RootPath: root}), nil
+}
@@ -0,0 +1,156 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrTagRecording is returned when deleting from the tag being recorded into.
+var ErrTagRecording = errors.New("tag is currently being recorded")
+
+// DeleteTag permanently removes a tag folder with everything in it and
+// returns the bytes freed. It refuses a folder holding the active recording.
+func (fb *FileBrowser) DeleteTag(tag string) (uint64, error) {
+	if err := fb.validateTag(tag); err != nil {
+		return 0, err
+	}
+	dir := fb.tagPath(tag)
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return 0, fmt.Errorf("tag '%s' is not a folder", tag)
+	}
+
+	var freed uint64
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if fb.isActive(path) {
+			return ErrTagRecording
+		}
+		if info, err := d.Info(); err == nil {
+			freed += uint64(info.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, err
+	}
+	fb.publishState(StateDisk)
+	slog.Info("Deleted tag", "tag", tag, "freed_bytes", freed)
+	return freed, nil
+}
+
+// DeletePlan lists what a delete would remove. Dry runs return it without
+// touching the filesystem.
+type DeletePlan struct {
+	Tags  []string      `json:"tags"`
+	Files []PlannedFile `json:"files"`
+	Count uint32        `json:"count"`
+}
+
+type PlannedFile struct {
+	Tag      string `json:"tag"`
+	FileName string `json:"filename"`
+}
+
+// PlanDelete lists the recordings in tag ("" for all tags) modified before
+// the given unix time (0 for no bound).
+func (fb *FileBrowser) PlanDelete(tag string, before int64) (*DeletePlan, error) {
+	tags := []string{tag}
+	if tag != "" {
+		if err := fb.validateTag(tag); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if tags, err = fb.getSortedTags(); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &DeletePlan{Tags: []string{}, Files: []PlannedFile{}}
+	for _, t := range tags {
+		files, err := fb.getSortedFiles(fb.tagPath(t))
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+		for _, f := range files {
+			if fb.isActive(filepath.Join(fb.tagPath(t), f.Name())) {
+				continue
+			}
+			if before > 0 {
+				info, err := f.Info()
+				if err != nil || !info.ModTime().Before(time.Unix(before, 0)) {
+					continue
+				}
+			}
+			plan.Files = append(plan.Files, PlannedFile{Tag: t, FileName: f.Name()})
+			matched = true
+		}
+		if matched {
+			plan.Tags = append(plan.Tags, t)
+		}
+	}
+
+	plan.Count = uint32(len(plan.Files))
+	return plan, nil
+}
+
+// DeleteRecording moves one recording of a tag, found by ID, to the trash
+// along with its sidecars. The active recording is refused.
+func (fb *FileBrowser) DeleteRecording(tag string, id uint32) error {
+	file, err := fb.GetRecordingByID(tag, id)
+	if err != nil {
+		return err
+	}
+	if file.InProgress {
+		return ErrRecordingInProgress
+	}
+	if err := fb.trashRecording(tag, file.FileName, time.Now()); err != nil {
+		return err
+	}
+
+	fb.purgeExpiredTrash()
+	fb.publishState(StateDisk)
+	return nil
+}
+
+// deletePlan moves every planned recording to the trash and returns how many
+// were moved.
+func (fb *FileBrowser) deletePlan(plan *DeletePlan) (uint32, error) {
+	op := fb.ops.Begin(OpDelete)
+	defer op.End()
+
+	batch := time.Now()
+	var count uint32
+	for i, f := range plan.Files {
+		op.SetProgress(i, len(plan.Files))
+		if err := fb.trashRecording(f.Tag, f.FileName, batch); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // Removed since planning
+			}
+			return count, err
+		}
+		count++
+	}
+
+	fb.purgeExpiredTrash()
+	fb.publishState(StateDisk)
+	slog.Info("Deleted recordings", "count", count)
+	return count, nil
+}
@@ -0,0 +1,67 @@
+package hardware
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Where the Pi's board model, OS release and kernel version are read from
+const (
+	deviceTreeModel = "/proc/device-tree/model"
+	osReleaseFile   = "/etc/os-release"
+	kernelRelease   = "/proc/sys/kernel/osrelease"
+)
+
+// DeviceInfo describes the hardware and the software under the server,
+// whose own version is in the version package. On modular hardware it
+// changes when a module is swapped, announced with StateDevice.
+type DeviceInfo struct {
+	// Model and Serial of a swappable camera module, empty to report the
+	// product's own
+	Model  string `json:"model,omitempty"`
+	Serial string `json:"serial,omitempty"`
+
+	HardwareRevision string `json:"hardware_revision"` // e.g. "Raspberry Pi Zero 2 W Rev 1.0"
+	SoftwareRevision string `json:"software_revision"` // OS and kernel
+}
+
+// boardModel reads the board's name from the device tree, "" if there is
+// none.
+func boardModel() string {
+	data, err := os.ReadFile(deviceTreeModel)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
+}
+
+// osVersion describes the OS, e.g. "Debian GNU/Linux 12 (bookworm), Linux
+// 6.6.31", or whichever half of that can be read.
+func osVersion() string {
+	var parts []string
+	if name := osReleaseName(); name != "" {
+		parts = append(parts, name)
+	}
+	if data, err := os.ReadFile(kernelRelease); err == nil {
+		parts = append(parts, "Linux "+strings.TrimSpace(string(data)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// osReleaseName returns PRETTY_NAME from os-release, "" if it's missing.
+func osReleaseName() string {
+	f, err := os.Open(osReleaseFile)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "PRETTY_NAME="); ok {
+			return strings.Trim(v, `"'`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+)
+
+// ErrStorageNotMounted is returned when the recordings storage isn't there yet.
+var ErrStorageNotMounted = errors.New("storage not mounted")
+
+// DiskController measures the filesystem holding the recordings.
+type DiskController struct {
+	RootPath string
+
+	// RequireMount expects RootPath to be a mount point (e.g. the SD card at
+	// /mnt/sdcard), so an empty directory left by a missing card isn't
+	// mistaken for the card.
+	RequireMount bool
+}
+
+// GetDiskStatus reports the size and free space of the filesystem. UsedMB
+// is everything that isn't free, including space reserved for root.
+// TrashMB is left to the caller.
+func (d DiskController) GetDiskStatus() (*DiskStatus, error) {
+	if _, err := os.Stat(d.RootPath); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrStorageNotMounted, d.RootPath)
+	}
+	if d.RequireMount {
+		mounted, err := isMountPoint(d.RootPath)
+		if err != nil {
+			return nil, err
+		}
+		if !mounted {
+			return nil, fmt.Errorf("%w: %s", ErrStorageNotMounted, d.RootPath)
+		}
+	}
+
+	total, free, err := statDisk(d.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskStatus{
+		TotalMB:  bytesToMB(total),
+		UsedMB:   bytesToMB(total - min(free, total)),
+		FreeMB:   bytesToMB(free),
+		ReadOnly: isReadOnly(d.RootPath),
+	}, nil
+}
+
+// bytesToMB converts to whole MB, clamped to what a uint32 holds.
+func bytesToMB(b uint64) uint32 {
+	return uint32(min(b/(1024*1024), math.MaxUint32))
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package hardware
+
+import "errors"
+
+var errDiskUnsupported = errors.New("disk measurement not supported on this platform")
+
+func statDisk(path string) (total, free uint64, err error) {
+	return 0, 0, errDiskUnsupported
+}
+
+func isReadOnly(path string) bool {
+	return false
+}
+
+func isMountPoint(path string) (bool, error) {
+	return false, errDiskUnsupported
+}
@@ -0,0 +1,44 @@
+//go:build unix
+
+package hardware
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+)
+
+// statDisk returns the size of the filesystem holding path and the bytes
+// available to unprivileged users.
+func statDisk(path string) (total, free uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	bsize := uint64(st.Bsize)
+	return uint64(st.Blocks) * bsize, uint64(st.Bavail) * bsize, nil
+}
+
+// isReadOnly reports whether path is on a read-only filesystem. Asking
+// for write access fails with EROFS there, whatever the permissions.
+func isReadOnly(path string) bool {
+	const wOK = 2
+	return errors.Is(syscall.Access(path, wOK), syscall.EROFS)
+}
+
+// isMountPoint reports whether path is on another device than its parent.
+func isMountPoint(path string) (bool, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	var self, parent syscall.Stat_t
+	if err := syscall.Stat(abs, &self); err != nil {
+		return false, err
+	}
+	if err := syscall.Stat(filepath.Dir(abs), &parent); err != nil {
+		return false, err
+	}
+	return self.Dev != parent.Dev || self.Ino == parent.Ino, nil // "/" is its own parent
+}
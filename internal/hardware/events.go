@@ -0,0 +1,77 @@
+package hardware
+
+import (
+	"sync"
+	"time"
+)
+
+// State change kinds
+const (
+	StateRecorder = "recorder" // Started, stopped, paused, reconfigured, ...
+	StateWifi     = "wifi"     // Credentials saved or a connection attempt ended
+	StateDisk     = "disk"     // Recordings finished, deleted, restored or purged
+	StateBattery  = "battery"  // Charger plugged in or out, simulated levels (real ones are polled)
+	StateSchedule = "schedule"
+	StateLocale   = "locale"
+	StateDevice   = "device" // Hardware swapped, see GetDeviceInfo
+)
+
+// StateEvent tells subscribers that part of the Controller's state changed.
+// It carries no state: subscribers read what they need back from the
+// Controller.
+type StateEvent struct {
+	Kind string `json:"kind"`
+	Unix int64  `json:"unix"`
+}
+
+// EventBus fans events out to subscribers. The zero value is ready to use.
+//
+// Publish runs the subscribers on the caller's goroutine, which may hold
+// the publisher's locks: a subscriber mustn't block or call back into the
+// publisher before returning.
+type EventBus[T any] struct {
+	mu      sync.Mutex
+	nextSub int
+	subs    map[int]func(T)
+}
+
+// Subscribe calls fn with every event published until cancel is called.
+func (b *EventBus[T]) Subscribe(fn func(T)) (cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs == nil {
+		b.subs = make(map[int]func(T))
+	}
+	b.nextSub++
+	id := b.nextSub
+	b.subs[id] = fn
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+// Publish sends ev to every subscriber.
+func (b *EventBus[T]) Publish(ev T) {
+	b.mu.Lock()
+	subs := make([]func(T), 0, len(b.subs))
+	for _, fn := range b.subs {
+		subs = append(subs, fn)
+	}
+	b.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(ev)
+	}
+}
+
+func (fb *FileBrowser) SubscribeState(fn func(StateEvent)) (cancel func()) {
+	return fb.state.Subscribe(fn)
+}
+
+// publishState tells subscribers that kind of state changed.
+func (fb *FileBrowser) publishState(kind string) {
+	fb.state.Publish(StateEvent{Kind: kind, Unix: time.Now().Unix()})
+}
@@ -0,0 +1,57 @@
+package hardware
+
+import (
+	"context"
+	"time"
+)
+
+// imuSampleInterval is how often StreamIMU reads the sensor (50Hz).
+const imuSampleInterval = 20 * time.Millisecond
+
+// standardGravity converts m/s² to g.
+const standardGravity = 9.80665
+
+// IMUSample is one accelerometer reading, in g.
+type IMUSample struct {
+	Millis uint32  `json:"millis"` // Since the recording started
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+	Z      float32 `json:"z"`
+}
+
+// noIMU is the stream of an idle recorder, already closed.
+func noIMU() <-chan IMUSample {
+	ch := make(chan IMUSample)
+	close(ch)
+	return ch
+}
+
+// streamIMU calls read every imuSampleInterval and sends its samples until
+// ctx is done or read reports the recording is over, then closes the
+// channel. Samples the receiver isn't ready for are dropped, so a slow
+// reader never stalls the sensor.
+func streamIMU(ctx context.Context, read func() (IMUSample, bool)) <-chan IMUSample {
+	ch := make(chan IMUSample, 1)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(imuSampleInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			sample, ok := read()
+			if !ok {
+				return
+			}
+			select {
+			case ch <- sample:
+			default:
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,37 @@
+package hardware
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// DefaultLocale is used until a locale is set.
+const DefaultLocale = "en-US"
+
+// ErrInvalidLocale is returned for a locale that isn't a language tag.
+var ErrInvalidLocale = errors.New("invalid locale")
+
+// localePattern accepts the common BCP 47 subset: a language, then an
+// optional script and region (e.g. "en", "en-US", "zh-Hant-TW", "es-419").
+var localePattern = regexp.MustCompile(`^(?i)[a-z]{2,3}(-[a-z]{4})?(-([a-z]{2}|[0-9]{3}))?$`)
+
+// canonicalLocale validates a locale and returns it in canonical case:
+// lowercase language, titlecase script, uppercase region.
+func canonicalLocale(locale string) (string, error) {
+	locale = strings.ReplaceAll(locale, "_", "-") // Accept POSIX style
+	if !localePattern.MatchString(locale) {
+		return "", ErrInvalidLocale
+	}
+
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i, p := range parts[1:] {
+		if len(p) == 4 {
+			parts[i+1] = strings.ToUpper(p[:1]) + strings.ToLower(p[1:])
+		} else {
+			parts[i+1] = strings.ToUpper(p)
+		}
+	}
+	return strings.Join(parts, "-"), nil
+}
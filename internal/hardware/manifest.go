@@ -0,0 +1,64 @@
+package hardware
+
+import "path/filepath"
+
+// TagManifest lists every recording of a tag, for clients planning a sync
+// without a request per file.
+type TagManifest struct {
+	Tag        string          `json:"tag"`
+	Count      uint32          `json:"count"`
+	TotalBytes uint64          `json:"total_bytes"`
+	Recordings []ManifestEntry `json:"recordings"` // In listing order
+}
+
+type ManifestEntry struct {
+	ID         uint32 `json:"id"`
+	FileName   string `json:"filename"`
+	SizeBytes  uint64 `json:"size_bytes"`
+	ModUnix    int64  `json:"mod_unix"`
+	CRC32      uint32 `json:"crc32"` // IEEE, 0 while in progress
+	InProgress bool   `json:"in_progress,omitempty"`
+}
+
+// GetTagManifest returns the manifest of a tag, checksumming its videos.
+func (fb *FileBrowser) GetTagManifest(tag string) (*TagManifest, error) {
+	if err := fb.validateTag(tag); err != nil {
+		return nil, err
+	}
+	dir := fb.tagPath(tag)
+	files, err := fb.getSortedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	op := fb.ops.Begin(OpManifest)
+	defer op.End()
+
+	m := &TagManifest{Tag: tag, Recordings: []ManifestEntry{}}
+	for i, f := range files {
+		op.SetProgress(i, len(files))
+		info, err := f.Info()
+		if err != nil {
+			continue // Removed since listing
+		}
+
+		path := filepath.Join(dir, f.Name())
+		entry := ManifestEntry{
+			ID:         recordingID(f.Name()),
+			FileName:   f.Name(),
+			SizeBytes:  uint64(info.Size()),
+			ModUnix:    info.ModTime().Unix(),
+			InProgress: fb.isActive(path),
+		}
+		if !entry.InProgress {
+			if entry.CRC32, err = checksum(path, info); err != nil {
+				return nil, err
+			}
+		}
+
+		m.Recordings = append(m.Recordings, entry)
+		m.TotalBytes += entry.SizeBytes
+	}
+	m.Count = uint32(len(m.Recordings))
+	return m, nil
+}
@@ -0,0 +1,79 @@
+package hardware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// RecordingMetadata is stored next to a video as <name>.json when the
+// recording has context worth keeping.
+type RecordingMetadata struct {
+	Reason   string `json:"reason,omitempty"` // Why it was recorded, or what triggered it
+	Operator string `json:"operator,omitempty"`
+
+	// SessionID is shared by the chunks of one recording, see newSessionID
+	SessionID string `json:"session_id,omitempty"`
+
+	// Cached CRC-32 of the video, valid while its size and mtime match
+	CRC32        uint32 `json:"crc32,omitempty"`
+	CRC32Size    int64  `json:"crc32_size,omitempty"`
+	CRC32ModNano int64  `json:"crc32_mod_nano,omitempty"`
+}
+
+func (md RecordingMetadata) empty() bool {
+	return md == RecordingMetadata{}
+}
+
+// cachedCRC32 returns the cached checksum if the video hasn't changed since.
+func (md RecordingMetadata) cachedCRC32(info fs.FileInfo) (uint32, bool) {
+	if md.CRC32Size != info.Size() || md.CRC32ModNano != info.ModTime().UnixNano() {
+		return 0, false
+	}
+	return md.CRC32, md.CRC32ModNano != 0
+}
+
+// newSessionID names a recording session by its start time, readable in
+// listings, and random bits so two devices (or a clock set back) can't
+// produce the same id, e.g. "20240615T093000Z-9f3a61c2".
+func newSessionID(start time.Time) string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return start.UTC().Format(sessionTimeLayout) + "-" + hex.EncodeToString(b[:])
+}
+
+// metadataPath returns the sidecar path for a video.
+func metadataPath(videoPath string) string {
+	return strings.TrimSuffix(videoPath, ".mp4") + ".json"
+}
+
+// readMetadata loads the sidecar of a video. Videos without one have empty
+// metadata.
+func readMetadata(videoPath string) (RecordingMetadata, error) {
+	var md RecordingMetadata
+	data, err := os.ReadFile(metadataPath(videoPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return md, nil
+	}
+	if err != nil {
+		return md, err
+	}
+	return md, json.Unmarshal(data, &md)
+}
+
+// writeMetadata saves the sidecar of a video, if there is anything to save.
+func writeMetadata(videoPath string, md RecordingMetadata) error {
+	if md.empty() {
+		return nil
+	}
+	data, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metadataPath(videoPath), data, 0644)
+}
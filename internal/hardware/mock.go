@@ -1,11 +1,24 @@
 package hardware
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	_ "embed"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
 	"log/slog"
-	"math/rand"
+	"math"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,26 +27,129 @@ import (
 type MockController struct {
 	FileBrowser // Embeds GetNumOfTags, GetTagInfoByIndex, etc.
 
+	// Clock drives the recording schedule. Nil uses the system clock.
+	Clock Clock
+
 	mu          sync.Mutex
 	isRecording bool
 
+	// Simulated hardware
+	battery   BatteryStatus
+	disk      DiskStatus
+	notFull   *DiskStatus // Disk before SimulateDiskFull, nil when not full
+	readOnly  bool        // Storage mounted read-only
+	device    DeviceInfo
+	wifiDelay time.Duration
+	fileTime  string // Time layout of video names
+	realMB    uint32 // See MockOptions.RealWriteMB
+	reserved  uint64 // MB held by PreallocateRecording
+
+	// Runtime stats
+	initAt            time.Time
+	recStartedAt      time.Time // Of the current chunk
+	sessionStart      time.Time // Of the recording, by the simulated clock
+	isPaused          bool
+	pausedAt          time.Time
+	pausedFor         time.Duration // Paused time of the current recording
+	recordingsCreated uint32
+
+	// Recorder history
+	events recorderLog
+
+	// Recording simulation
+	simStop      chan struct{}
+	tagBaseBytes uint64 // Tag size when the recording started
+	videoPath    string // File being recorded
+	recMeta      RecordingMetadata
+	baseConfig   *RecorderParameters // Restored on stop when overridden
+	override     RecorderParameters  // The override, while baseConfig is set
+	recSession   uint64              // Bumped by every start
+	live         RecorderLiveStats
+
+	// Auto-restart after a fault, with backoff
+	autoRestart     bool
+	restartAttempts int
+	restartGen      uint64 // Bumped to cancel a pending restart
+
+	// Recording schedule
+	schedule     []RecordingWindow
+	schedStop    chan struct{}
+	schedTag     string // Tag the scheduler is recording into
+	schedHandled string // Window occurrence already started (or stopped by hand)
+
 	// Configuration State
 	recConfig  RecorderParameters
 	wifiConfig WifiParameters
+	wifiState  string // WifiLinkStatus.State, "" before any connection
+	locale     string
+	timeOffset time.Duration // Set by SetSystemTime, added to the clock
+
+	settingsMu sync.Mutex // Serializes updates of the settings file
 }
 
-func NewController() Controller {
-	// Setup a local folder for testing
-	cwd, _ := os.Getwd()
-	localTestPath := filepath.Join(cwd, "test_recordings")
+// MockOptions configure NewMockController. Only RootPath is required.
+type MockOptions struct {
+	RootPath string
+	Clock    Clock // Nil uses the system clock
 
+	// Simulated hardware. Nil battery is 88% and discharging, nil disk is a
+	// 64GB card with 12.5GB used. TrashMB is always measured.
+	Battery *BatteryStatus
+	Disk    *DiskStatus
+
+	// WifiConnectDelay is how long ConnectToWifi takes, 0 for
+	// defaultWifiConnectDelay. Longer than the caller's deadline simulates
+	// an unreachable network.
+	WifiConnectDelay time.Duration
+
+	// FileTimeFormat is the time layout in video names, empty for
+	// DefaultFileTimeFormat. It should sort chronologically.
+	FileTimeFormat string
+
+	// RealWriteMB makes finished videos hold real bytes, at most this many
+	// MB each, and GetDiskStatus measure the filesystem instead of
+	// simulating it. 0 keeps the faster sparse files.
+	RealWriteMB uint32
+}
+
+const defaultWifiConnectDelay = 500 * time.Millisecond
+
+var (
+	defaultMockBattery = BatteryStatus{Percentage: 88, EstimatedMins: 145}
+	defaultMockDisk    = DiskStatus{TotalMB: 64000, UsedMB: 12500, FreeMB: 51500}
+	defaultMockStorage = StorageInfo{FSType: "exfat", Label: "BLUEOWL", Device: "/dev/mmcblk0p1"}
+)
+
+func (m *MockController) backend() (string, string) {
+	return BackendMock, m.RootPath
+}
+
+// NewMockController returns the concrete mock, for callers that want its
+// Simulate* methods.
+func NewMockController(opts MockOptions) *MockController {
 	// Ensure the root exists
-	_ = os.MkdirAll(localTestPath, 0755)
+	_ = os.MkdirAll(opts.RootPath, 0755)
 
-	return &MockController{
+	battery, disk := defaultMockBattery, defaultMockDisk
+	wifiDelay := cmp.Or(opts.WifiConnectDelay, defaultWifiConnectDelay)
+	if opts.Battery != nil {
+		battery = *opts.Battery
+	}
+	if opts.Disk != nil {
+		disk = *opts.Disk
+	}
+
+	m := &MockController{
 		FileBrowser: FileBrowser{
-			RootPath: localTestPath,
+			RootPath: opts.RootPath,
 		},
+		Clock:     opts.Clock,
+		battery:   battery,
+		disk:      disk,
+		wifiDelay: wifiDelay,
+		fileTime:  opts.FileTimeFormat,
+		locale:    DefaultLocale,
+		realMB:    opts.RealWriteMB,
 		recConfig: RecorderParameters{
 			FPS:         30,
 			Bitrate:     5000000,
@@ -46,16 +162,56 @@ func NewController() Controller {
 			Password: "",
 		},
 	}
+	m.events.state = &m.FileBrowser
+	m.makeThumbnail = writeMockThumbnail
+	return m
 }
 
 // --- Lifecycle ---
 
 func (m *MockController) Init() error {
+	if err := m.EnsureRoot(); err != nil {
+		return err
+	}
+
+	st, err := loadSettings(m.RootPath)
+	if err != nil {
+		slog.Warn("[MOCK] Ignoring unreadable settings", "err", err)
+	}
+
+	if isReadOnly(m.RootPath) {
+		slog.Warn("[MOCK] Storage is read-only, recording will fail", "root_path", m.RootPath)
+	}
+
+	m.mu.Lock()
+	m.initAt = time.Now()
+	m.schedule = st.Schedule
+	m.locale = cmp.Or(st.Locale, DefaultLocale)
+	m.schedStop = make(chan struct{})
+	go m.runScheduler(m.schedStop)
+	m.mu.Unlock()
+
 	slog.Info("[MOCK] Hardware Initialized", "root_path", m.RootPath)
+	m.FileBrowser.Start()
 	return nil
 }
 
 func (m *MockController) Close() {
+	m.mu.Lock()
+	m.cancelRestartLocked()
+	if m.isRecording {
+		if err := m.stopLocked(nil); err != nil {
+			slog.Error("[MOCK] Failed to stop recorder", "err", err)
+		}
+	}
+	if m.schedStop != nil {
+		close(m.schedStop)
+		m.schedStop = nil
+	}
+	m.mu.Unlock()
+
+	m.FileBrowser.Close()
+
 	slog.Info("[MOCK] Hardware Shutdown")
 }
 
@@ -65,26 +221,53 @@ func (m *MockController) SetupWifi(ssid, pwd string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.wifiConfig.SSID = ssid
-	m.wifiConfig.Password = pwd
+	m.wifiConfig = WifiParameters{SSID: ssid, Password: pwd}
+	m.publishState(StateWifi)
 
 	slog.Info("[MOCK] Wifi Credentials Saved", "ssid", ssid)
 	return nil
 }
 
-func (m *MockController) ConnectToWifi() error {
+func (m *MockController) ConnectToWifi(ctx context.Context) error {
 	slog.Info("[MOCK] Connecting to Wifi...")
-	time.Sleep(500 * time.Millisecond) // Simulate delay
+	op := m.ops.Begin(OpWifiConnect)
+	defer op.End()
+	defer m.publishState(StateWifi) // Connected or not
 
 	m.mu.Lock()
-	ssid := m.wifiConfig.SSID
+	delay := m.wifiDelay
+	m.wifiState = WifiConnecting
+	m.publishState(StateWifi)
 	m.mu.Unlock()
 
-	if ssid == "" {
-		return fmt.Errorf("no wifi credentials configured")
+	// Simulate delay
+	var err error
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		err = ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = ErrWifiTimeout
+		}
+		slog.Warn("[MOCK] Wifi connection aborted", "err", err)
 	}
 
-	slog.Info("[MOCK] Wifi Connected", "ssid", ssid)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil && m.wifiConfig.SSID == "" {
+		err = fmt.Errorf("no wifi credentials configured")
+	}
+	m.wifiConfig.Connected = err == nil
+	m.wifiConfig.LastError = ""
+	m.wifiState = WifiConnected
+	if err != nil {
+		m.wifiConfig.LastError = err.Error()
+		m.wifiState = WifiFailed
+		return err
+	}
+
+	slog.Info("[MOCK] Wifi Connected", "ssid", m.wifiConfig.SSID)
 	return nil
 }
 
@@ -93,28 +276,182 @@ func (m *MockController) GetWifiDetails() (*WifiParameters, error) {
 	defer m.mu.Unlock()
 
 	// Return a copy to avoid race conditions
-	return &WifiParameters{
-		SSID:     m.wifiConfig.SSID,
-		Password: m.wifiConfig.Password,
-	}, nil
+	c := m.wifiConfig
+	return &c, nil
+}
+
+// mockWifiIP and mockWifiRSSI describe the mock's Wifi link once connected.
+const (
+	mockWifiIP   = "192.168.1.42"
+	mockWifiRSSI = -55
+)
+
+func (m *MockController) GetWifiStatus() (*WifiLinkStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := &WifiLinkStatus{State: cmp.Or(m.wifiState, WifiDisconnected)}
+	if status.State == WifiDisconnected {
+		return status, nil
+	}
+	status.SSID = m.wifiConfig.SSID
+	if status.State == WifiConnected {
+		status.IPAddress, status.RSSI = mockWifiIP, mockWifiRSSI
+	}
+	return status, nil
+}
+
+func (m *MockController) ScanWifi(ctx context.Context, found func(WifiNetwork)) error {
+	networks := []WifiNetwork{
+		{SSID: "Augmodo-Office", RSSI: -42, Security: "WPA2"},
+		{SSID: "BestBuy-Guest", RSSI: -67, Security: "open"},
+		{SSID: "Warehouse-5G", RSSI: -74, Security: "WPA3"},
+	}
+
+	slog.Info("[MOCK] Scanning Wifi...")
+	op := m.ops.Begin(OpWifiScan)
+	defer op.End()
+
+	for i, n := range networks {
+		op.SetProgress(i, len(networks))
+		select {
+		case <-time.After(300 * time.Millisecond):
+			found(n)
+		case <-ctx.Done():
+			slog.Info("[MOCK] Wifi scan aborted", "err", ctx.Err())
+			return ctx.Err()
+		}
+	}
+	return nil
 }
 
 // --- Battery & Storage ---
 
 func (m *MockController) GetBatteryStatus() (*BatteryStatus, error) {
-	return &BatteryStatus{
-		Percentage:    88,
-		IsCharging:    false,
-		EstimatedMins: 145,
-	}, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := m.battery
+	return &st, nil
+}
+
+// SimulateCharging plugs the charger in or out.
+func (m *MockController) SimulateCharging(charging bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.battery.IsCharging = charging
+	if charging {
+		m.battery.EstimatedMins = uint16(100-m.battery.Percentage) * 2 // Until full
+	} else {
+		m.battery.EstimatedMins = uint16(m.battery.Percentage) * 165 / 100
+	}
+	m.publishState(StateBattery)
+	slog.Info("[MOCK] Charger", "plugged_in", charging)
+}
+
+// SimulateBatteryLevel sets the battery percentage, e.g. to fake a low
+// battery.
+func (m *MockController) SimulateBatteryLevel(percentage uint8) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.battery.Percentage = min(percentage, 100)
+	if !m.battery.IsCharging {
+		m.battery.EstimatedMins = uint16(m.battery.Percentage) * 165 / 100
+	}
+	m.publishState(StateBattery)
+	slog.Info("[MOCK] Battery level", "percentage", m.battery.Percentage)
+}
+
+// SimulateDiskFull uses up the simulated disk's free space, or gives back
+// the usage it had before when full is false.
+func (m *MockController) SimulateDiskFull(full bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case full && m.notFull == nil:
+		before := m.disk
+		m.notFull = &before
+		m.disk.UsedMB, m.disk.FreeMB = m.disk.TotalMB, 0
+	case !full && m.notFull != nil:
+		m.disk = *m.notFull
+		m.notFull = nil
+	}
+	m.publishState(StateDisk)
+	slog.Info("[MOCK] Disk full", "full", full)
+}
+
+// SimulateReadOnlyStorage remounts the simulated card read-only, as the
+// kernel does after filesystem errors, or back read-write.
+func (m *MockController) SimulateReadOnlyStorage(readOnly bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readOnly = readOnly
+	m.publishState(StateDisk)
+	slog.Info("[MOCK] Storage read-only", "read_only", readOnly)
+}
+
+// SimulateDeviceSwap fits a camera module with another model and serial,
+// empty ones restoring the product's own.
+func (m *MockController) SimulateDeviceSwap(model, serial string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.device.Model, m.device.Serial = model, serial
+	m.publishState(StateDevice)
+	slog.Info("[MOCK] Camera module swapped", "model", model, "serial", serial)
+}
+
+// SimulateWifiDisconnect drops the Wifi connection as if the network went
+// away.
+func (m *MockController) SimulateWifiDisconnect(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wifiConfig.Connected = false
+	m.wifiConfig.LastError = reason
+	m.wifiState = WifiDisconnected
+	m.publishState(StateWifi)
+	slog.Info("[MOCK] Wifi disconnected", "reason", reason)
 }
 
 func (m *MockController) GetDiskStatus() (*DiskStatus, error) {
-	return &DiskStatus{
-		TotalMB: 64000,
-		UsedMB:  12500,
-		FreeMB:  51500,
-	}, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, err := m.diskLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	st.TrashMB = m.TrashSizeMB()
+	return &st, nil
+}
+
+// GetStorageInfo reports an exFAT card mounted at RootPath.
+func (m *MockController) GetStorageInfo() (*StorageInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info := defaultMockStorage
+	info.MountPoint = m.RootPath
+	info.ReadOnly = m.readOnly || isReadOnly(m.RootPath)
+	return &info, nil
+}
+
+// diskLocked returns the disk usage, counting reserved space as used.
+// Caller must hold m.mu.
+func (m *MockController) diskLocked() (DiskStatus, error) {
+	st := m.disk
+	if m.realMB > 0 {
+		measured, err := DiskController{RootPath: m.RootPath}.GetDiskStatus()
+		if err != nil {
+			return st, err
+		}
+		st = *measured
+	}
+	st.ReadOnly = m.readOnly || isReadOnly(m.RootPath)
+
+	reserved := uint32(min(m.reserved, uint64(st.FreeMB)))
+	st.FreeMB -= reserved
+	st.UsedMB += reserved
+	return st, nil
 }
 
 // --- Recorder Controls ---
@@ -122,7 +459,8 @@ func (m *MockController) GetDiskStatus() (*DiskStatus, error) {
 func (m *MockController) SetupRecorder(params RecorderParameters) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.recConfig = params
+	m.setConfigLocked(params)
+	m.publishState(StateRecorder)
 	slog.Info("[MOCK] Recorder Configured",
 		"fps", params.FPS,
 		"bitrate", params.Bitrate,
@@ -130,68 +468,995 @@ func (m *MockController) SetupRecorder(params RecorderParameters) error {
 	return nil
 }
 
-func (m *MockController) StartRecorder(folderTag string) error {
+func (m *MockController) StartRecorder(ctx context.Context, folderTag string) error {
+	return m.StartRecorderWithOptions(ctx, StartOptions{Tag: folderTag})
+}
+
+func (m *MockController) StartRecorderWithOptions(ctx context.Context, opts StartOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A start losing a race mustn't disturb the recording that won it
+	if m.isRecording {
+		return ErrAlreadyRecording
+	}
+	m.cancelRestartLocked()
+	m.restartAttempts = 0
+	m.schedTag = ""
+	if opts.Preallocate {
+		bitrate, secs := m.recConfig.Bitrate, m.recConfig.ChunkSecs
+		if opts.Config != nil {
+			bitrate = cmp.Or(opts.Config.Bitrate, bitrate)
+			secs = cmp.Or(opts.Config.ChunkSecs, secs)
+		}
+		if err := m.preallocateLocked(estimateRecordingMB(bitrate, secs)); err != nil {
+			return err
+		}
+	}
+	if err := m.startLocked(opts.Tag); err != nil {
+		m.reserved = 0
+		return err
+	}
+	m.recMeta.Reason, m.recMeta.Operator = opts.Reason, opts.Operator
+	if opts.Config != nil {
+		m.overrideConfigLocked(*opts.Config)
+	}
+	return nil
+}
+
+func (m *MockController) PreallocateRecording(estimatedMB uint64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.preallocateLocked(estimatedMB)
+}
+
+// preallocateLocked replaces any previous reservation. Caller must hold m.mu.
+func (m *MockController) preallocateLocked(estimatedMB uint64) error {
+	m.reserved = 0
+	st, err := m.diskLocked()
+	if err != nil {
+		return err
+	}
+	if estimatedMB > uint64(st.FreeMB) {
+		return fmt.Errorf("%w: need %d MB, %d MB free", ErrInsufficientSpace, estimatedMB, st.FreeMB)
+	}
+
+	m.reserved = estimatedMB
+	m.publishState(StateDisk)
+	slog.Info("[MOCK] Recording space reserved", "mb", estimatedMB)
+	return nil
+}
+
+// estimateRecordingMB sizes a recording of one chunk at the given bitrate.
+func estimateRecordingMB(bitrate uint32, chunkSecs uint16) uint64 {
+	secs := uint64(cmp.Or(chunkSecs, preallocateDefaultSecs))
+	return (uint64(bitrate)/8*secs + 1024*1024 - 1) / (1024 * 1024)
+}
+
+// preallocateDefaultSecs is the recording length assumed when chunking is off.
+const preallocateDefaultSecs = 300
+
+// overrideConfigLocked applies a per-recording config until the recording
+// stops. Caller must hold m.mu.
+func (m *MockController) overrideConfigLocked(c RecorderParameters) {
+	base := m.recConfig
+	m.baseConfig, m.override = &base, c
+	m.recConfig = withOverride(m.recConfig, c)
+	slog.Info("[MOCK] Recorder config overridden",
+		"fps", m.recConfig.FPS,
+		"bitrate", m.recConfig.Bitrate,
+		"chunk_secs", m.recConfig.ChunkSecs)
+}
+
+// restoreConfigLocked undoes overrideConfigLocked. Caller must hold m.mu.
+func (m *MockController) restoreConfigLocked() {
+	if m.baseConfig == nil {
+		return
+	}
+	tag, lastErr := m.recConfig.FilenameTag, m.recConfig.LastError
+	m.recConfig = *m.baseConfig
+	m.recConfig.FilenameTag, m.recConfig.LastError = tag, lastErr
+	m.baseConfig = nil
+}
+
+// configLocked returns the recorder config as set, without a per-recording
+// override. Caller must hold m.mu.
+func (m *MockController) configLocked() RecorderParameters {
+	if m.baseConfig != nil {
+		return *m.baseConfig
+	}
+	return m.recConfig
+}
+
+// setConfigLocked replaces the recorder config, keeping the state of the
+// running recording. During a per-recording override it replaces the
+// config restored at stop, and the overridden settings stay until then.
+// Caller must hold m.mu.
+func (m *MockController) setConfigLocked(params RecorderParameters) {
+	params.FilenameTag, params.LastError = m.recConfig.FilenameTag, m.recConfig.LastError
+	if m.baseConfig != nil {
+		base := params
+		m.baseConfig = &base
+		params = withOverride(params, m.override)
+	}
+	m.recConfig = params
+}
 
+// startLocked starts recording into folderTag. Caller must hold m.mu.
+func (m *MockController) startLocked(folderTag string) error {
 	if m.isRecording {
-		return fmt.Errorf("already recording")
+		return ErrAlreadyRecording
+	}
+	if err := m.validateTag(folderTag); err != nil {
+		return err
+	}
+	if m.readOnly || isReadOnly(m.RootPath) {
+		return fmt.Errorf("%w: %s", ErrStorageReadOnly, m.RootPath)
+	}
+
+	tagBytes, err := m.tagSizeBytes(folderTag)
+	if err != nil {
+		return err
+	}
+	if quota := uint64(m.recConfig.TagQuotaMB) * 1024 * 1024; quota > 0 && tagBytes >= quota {
+		return ErrTagQuotaExceeded
+	}
+
+	m.sessionStart = m.now()
+	m.recMeta = RecordingMetadata{SessionID: newSessionID(m.sessionStart)}
+	if err := m.openVideoLocked(folderTag); err != nil {
+		return err
 	}
 
 	m.isRecording = true
 	m.recConfig.FilenameTag = folderTag
+	m.recConfig.LastError = ""
+	m.recStartedAt = time.Now()
+	m.isPaused, m.pausedFor = false, 0
+	m.tagBaseBytes = tagBytes
+	m.recSession++
+	m.live = RecorderLiveStats{}
 
-	// Create physical folder
-	fullPath := filepath.Join(m.RootPath, folderTag)
+	m.simStop = make(chan struct{})
+	go m.simulateRecording(m.simStop)
+
+	m.events.add(RecorderStarted, folderTag, "")
+	slog.Info("[MOCK] Recording STARTED", "tag", folderTag)
+	return nil
+}
+
+// openVideoLocked creates the next video file in tag. It exists, empty,
+// from the start like the real encoder's output.
+func (m *MockController) openVideoLocked(tag string) error {
+	fullPath := m.tagPath(tag)
 	if err := os.MkdirAll(fullPath, 0755); err != nil {
 		return err
 	}
 
-	slog.Info("[MOCK] Recording STARTED", "tag", folderTag)
+	videoPath := filepath.Join(fullPath, recordingFileName(m.now(), m.fileTime))
+	if err := os.WriteFile(videoPath, nil, 0644); err != nil {
+		return err
+	}
+	m.videoPath = videoPath
+	m.setActiveRecording(videoPath, m.recMeta.SessionID)
+	return nil
+}
+
+// simulateRecording stands in for the encoder while recording: every second
+// it measures output near the configured rate, occasionally dropping frames
+// or failing to encode one, checks the simulated tag size against the quota
+// and ends the chunk after ChunkSecs, until stop is closed.
+func (m *MockController) simulateRecording(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		m.mu.Lock()
+		m.simulateLiveStatsLocked()
+
+		quota := uint64(m.recConfig.TagQuotaMB) * 1024 * 1024
+		if m.isRecording && quota > 0 && m.tagBaseBytes+m.recordedBytesLocked() >= quota {
+			slog.Warn("[MOCK] Tag quota reached, stopping", "tag", m.recConfig.FilenameTag, "quota_mb", m.recConfig.TagQuotaMB)
+			if err := m.stopLocked(ErrTagQuotaExceeded); err != nil {
+				slog.Error("[MOCK] Failed to stop recorder", "err", err)
+			}
+		}
+
+		chunk := time.Duration(m.recConfig.ChunkSecs) * time.Second
+		if m.isRecording && chunk > 0 && m.recordedForLocked() >= chunk {
+			var err error
+			if m.autoRestart {
+				err = m.rollOverLocked()
+			} else {
+				err = m.stopLocked(nil)
+			}
+			if err != nil {
+				slog.Error("[MOCK] Failed to end chunk", "err", err)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// simulateLiveStatsLocked measures one second of simulated encoder output.
+func (m *MockController) simulateLiveStatsLocked() {
+	if !m.isRecording || m.isPaused {
+		return
+	}
+
+	var dropped uint32
+	if rand.IntN(10) == 0 {
+		dropped = uint32(rand.IntN(3) + 1)
+	}
+	fps := float32(m.recConfig.FPS) - float32(dropped)
+	m.live.FPS = max(fps, 0)
+	m.live.Bitrate = uint32(float64(m.recConfig.Bitrate) * (0.95 + rand.Float64()*0.1))
+	m.live.DroppedFrames += dropped
+	if rand.IntN(50) == 0 {
+		m.live.EncodeErrors++
+	}
+}
+
+// recordedForLocked is how long the current recording has been capturing,
+// excluding pauses.
+func (m *MockController) recordedForLocked() time.Duration {
+	d := time.Since(m.recStartedAt) - m.pausedFor
+	if m.isPaused {
+		d -= time.Since(m.pausedAt)
+	}
+	return d
+}
+
+// recordedBytesLocked is the simulated size of the current recording.
+func (m *MockController) recordedBytesLocked() uint64 {
+	return uint64(m.recordedForLocked().Seconds() * float64(m.recConfig.Bitrate) / 8)
+}
+
+func (m *MockController) PauseRecorder() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.isRecording {
+		return fmt.Errorf("not recording")
+	}
+	if m.isPaused {
+		return nil
+	}
+	m.isPaused, m.pausedAt = true, time.Now()
+	m.live.FPS, m.live.Bitrate = 0, 0
+	m.events.add(RecorderPaused, m.recConfig.FilenameTag, "")
+	slog.Info("[MOCK] Recording PAUSED", "tag", m.recConfig.FilenameTag)
+	return nil
+}
+
+func (m *MockController) ResumeRecorder() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.isRecording || !m.isPaused {
+		return fmt.Errorf("not paused")
+	}
+	m.isPaused = false
+	m.pausedFor += time.Since(m.pausedAt)
+	m.events.add(RecorderResumed, m.recConfig.FilenameTag, "")
+	slog.Info("[MOCK] Recording RESUMED", "tag", m.recConfig.FilenameTag)
 	return nil
 }
 
 func (m *MockController) StopRecorder() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.cancelRestartLocked()
+	return m.stopLocked(nil)
+}
 
+// stopLocked finalizes the current recording. reason is recorded as the
+// recorder's LastError when the stop wasn't requested. Caller must hold m.mu.
+func (m *MockController) stopLocked(reason error) error {
 	if !m.isRecording {
 		return fmt.Errorf("not recording")
 	}
 
 	tag := m.recConfig.FilenameTag
-	timestamp := time.Now().Format("150405")
-	baseName := fmt.Sprintf("vid_%s", timestamp)
-	folderPath := filepath.Join(m.RootPath, tag)
+	fileName, err := m.finalizeVideoLocked()
+	if err != nil {
+		// Stop all the same, or every later stop fails the same way
+		slog.Error("[MOCK] Failed to finalize recording", "tag", tag, "err", err)
+		reason = errors.Join(reason, err)
+	}
+	m.endLocked(tag, fileName, reason)
+	return nil
+}
 
-	// 1. Create Dummy Video
-	videoPath := filepath.Join(folderPath, baseName+".mp4")
-	if err := os.WriteFile(videoPath, []byte("mock-header"), 0644); err != nil {
+// endLocked leaves the recording state once the video is finalized, or
+// failed to be. Caller must hold m.mu.
+func (m *MockController) endLocked(tag, fileName string, reason error) {
+	m.isRecording, m.isPaused = false, false
+	m.live.FPS, m.live.Bitrate = 0, 0
+	m.restoreConfigLocked()
+	m.recConfig.FilenameTag = ""
+	close(m.simStop)
+	if reason != nil {
+		m.recConfig.LastError = reason.Error()
+		m.events.add(RecorderErrored, tag, reason.Error())
+	}
+	m.events.add(RecorderStopped, tag, fileName)
+
+	slog.Info("[MOCK] Recording STOPPED", "tag", tag, "file", fileName)
+}
+
+// rollOverLocked closes the current chunk and continues recording the same
+// tag into a new file. Caller must hold m.mu.
+func (m *MockController) rollOverLocked() error {
+	tag := m.recConfig.FilenameTag
+	fileName, err := m.finalizeVideoLocked()
+	if err != nil {
+		m.endLocked(tag, "", err)
+		return err
+	}
+
+	if err := m.openVideoLocked(tag); err != nil {
+		m.isRecording = false // Nothing left to finalize
+		m.restoreConfigLocked()
+		m.recConfig.FilenameTag = ""
+		close(m.simStop)
+		m.recConfig.LastError = err.Error()
+		m.events.add(RecorderErrored, tag, err.Error())
 		return err
 	}
+	m.recStartedAt = time.Now()
+	m.pausedFor = 0
+	m.tagBaseBytes, _ = m.tagSizeBytes(tag)
+
+	m.events.add(RecorderRolledOver, tag, fileName)
+	slog.Info("[MOCK] Recording rolled over", "tag", tag, "file", fileName)
+	return nil
+}
+
+// finalizeVideoLocked gives the current video its simulated size and writes
+// its sidecars. Caller must hold m.mu.
+func (m *MockController) finalizeVideoLocked() (string, error) {
+	videoPath := m.videoPath
+	baseName := strings.TrimSuffix(filepath.Base(videoPath), ".mp4")
+	folderPath := filepath.Dir(videoPath)
+	defer func() {
+		// The video is done with even if finalizing it failed
+		m.setActiveRecording("", "")
+		m.videoPath = ""
+		m.reserved = 0
+		m.publishState(StateDisk)
+	}()
+
+	// 1. Finalize Dummy Video
+	if err := os.WriteFile(videoPath, []byte("mock-header"), 0644); err != nil {
+		return "", err
+	}
 
-	// Sparse file trick for realistic size
-	fakeSize := int64(rand.Intn(100)+20) * 1024 * 1024
-	_ = os.Truncate(videoPath, fakeSize)
+	// Sparse file trick for realistic size, unless real writes are wanted
+	fakeSize := max(int64(m.recordedBytesLocked()), int64(len("mock-header")))
+	if m.realMB > 0 {
+		limit := int64(m.realMB) * 1024 * 1024
+		if err := fillFile(videoPath, min(fakeSize, limit)); err != nil {
+			return "", err
+		}
+	} else {
+		_ = os.Truncate(videoPath, fakeSize)
+	}
 
 	// 2. Create Dummy IMU
 	imuPath := filepath.Join(folderPath, baseName+".imu")
 	_ = os.WriteFile(imuPath, []byte("ts,x,y,z\n"), 0644)
 
 	// 3. Create Dummy Thumbnail
-	thumbPath := filepath.Join(folderPath, baseName+".jpg")
-	_ = os.WriteFile(thumbPath, []byte("fake-jpg"), 0644)
+	_ = writeMockThumbnail(videoPath, filepath.Join(folderPath, baseName+".jpg"))
 
-	m.isRecording = false
-	m.recConfig.FilenameTag = ""
+	// 4. Metadata, if any
+	if err := writeMetadata(videoPath, m.recMeta); err != nil {
+		slog.Warn("[MOCK] Failed to write recording metadata", "err", err)
+	}
+
+	m.recordingsCreated++
+	return baseName + ".mp4", nil
+}
+
+// fillFile grows a file to size with zeros that are actually written, so
+// the filesystem allocates them.
+func fillFile(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	off, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 1024*1024)
+	for ; off < size; off += int64(len(buf)) {
+		if _, err := f.Write(buf[:min(int64(len(buf)), size-off)]); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
+
+// Auto-restart backoff: the delay doubles with each restart that follows a
+// fault, and resets once a recording has run for restartResetAfter.
+const (
+	restartBaseDelay  = time.Second
+	restartMaxDelay   = time.Minute
+	restartResetAfter = time.Minute
+)
+
+func (m *MockController) SetAutoRestart(enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	slog.Info("[MOCK] Recording STOPPED", "file", videoPath)
+	m.autoRestart = enabled
+	if !enabled {
+		m.cancelRestartLocked()
+	}
+	m.publishState(StateRecorder)
+	slog.Info("[MOCK] Auto-restart set", "enabled", enabled)
 	return nil
 }
 
+// SimulateRecorderFault stops the recording as if the encoder had crashed.
+func (m *MockController) SimulateRecorderFault(err error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tag := m.recConfig.FilenameTag
+	if stopErr := m.stopLocked(err); stopErr != nil {
+		return stopErr
+	}
+	if m.autoRestart {
+		m.scheduleRestartLocked(tag)
+	}
+	return nil
+}
+
+// scheduleRestartLocked restarts recording into tag after the backoff delay.
+// Caller must hold m.mu.
+func (m *MockController) scheduleRestartLocked(tag string) {
+	if time.Since(m.recStartedAt) >= restartResetAfter {
+		m.restartAttempts = 0
+	}
+	delay := restartMaxDelay
+	if m.restartAttempts < 6 {
+		delay = min(restartBaseDelay<<m.restartAttempts, restartMaxDelay)
+	}
+	m.restartAttempts++
+
+	m.restartGen++
+	gen := m.restartGen
+	time.AfterFunc(delay, func() { m.restart(gen, tag) })
+	slog.Warn("[MOCK] Recorder restart scheduled", "tag", tag, "delay", delay, "attempt", m.restartAttempts)
+}
+
+func (m *MockController) restart(gen uint64, tag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if gen != m.restartGen || m.isRecording || !m.autoRestart {
+		return
+	}
+	if err := m.startLocked(tag); err != nil {
+		slog.Error("[MOCK] Recorder restart failed", "tag", tag, "err", err)
+		m.events.add(RecorderErrored, tag, err.Error())
+		if !errors.Is(err, ErrTagQuotaExceeded) {
+			m.scheduleRestartLocked(tag)
+		}
+	}
+}
+
+// cancelRestartLocked drops a pending restart. Caller must hold m.mu.
+func (m *MockController) cancelRestartLocked() {
+	m.restartGen++
+}
+
+func (m *MockController) GetRecorderEvents(since int64) ([]RecorderEvent, error) {
+	return m.events.since(since), nil
+}
+
+func (m *MockController) GetCurrentSessionID() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.isRecording {
+		return "", nil
+	}
+	return m.recMeta.SessionID, nil
+}
+
 func (m *MockController) GetRecorderInfo() (*RecorderParameters, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	c := m.recConfig
+	c.AutoRestart = m.autoRestart
+	c.Paused = m.isRecording && m.isPaused
+	c.StartedUnix = 0 // Ignored by SetupRecorder
+	if m.isRecording {
+		c.StartedUnix = m.sessionStart.Unix()
+	}
 	return &c, nil
 }
+
+func (m *MockController) GetRecorderLiveStats() (*RecorderLiveStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.live
+	return &st, nil
+}
+
+// --- Triggers ---
+
+// TriggerRecording records TriggerRecordDuration into a tag derived from the
+// reason, which is kept in the recording's metadata.
+func (m *MockController) TriggerRecording(reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isRecording {
+		return ErrAlreadyRecording
+	}
+	tag := triggerTag(reason)
+	m.cancelRestartLocked()
+	m.schedTag = ""
+	if err := m.startLocked(tag); err != nil {
+		return err
+	}
+	m.recMeta.Reason = reason
+
+	session := m.recSession
+	time.AfterFunc(TriggerRecordDuration, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.isRecording && m.recSession == session {
+			if err := m.stopLocked(nil); err != nil {
+				slog.Error("[MOCK] Failed to end triggered recording", "err", err)
+			}
+		}
+	})
+
+	slog.Info("[MOCK] Recording triggered", "reason", reason, "tag", tag)
+	return nil
+}
+
+// SimulateTrigger stands in for the trigger input (a GPIO line on the
+// device) firing.
+func (m *MockController) SimulateTrigger(reason string) error {
+	slog.Info("[MOCK] Trigger input fired", "reason", reason)
+	return m.TriggerRecording(reason)
+}
+
+// --- Snapshot ---
+
+// mockSnapshot is a color bar test card standing in for the camera.
+//
+//go:embed mockdata/snapshot.jpg
+var mockSnapshot []byte
+
+// CaptureSnapshot returns the test card. The mock camera isn't busy while
+// recording, but it refuses like the real one.
+func (m *MockController) CaptureSnapshot() ([]byte, error) {
+	m.mu.Lock()
+	recording := m.isRecording
+	m.mu.Unlock()
+	if recording {
+		return nil, ErrRecordingInProgress
+	}
+	slog.Info("[MOCK] Snapshot captured", "bytes", len(mockSnapshot))
+	return slices.Clone(mockSnapshot), nil
+}
+
+// --- Schedule ---
+
+// scheduleInterval is how often the scheduler checks the windows.
+const scheduleInterval = 15 * time.Second
+
+func (m *MockController) SetSchedule(windows []RecordingWindow) error {
+	if err := validateSchedule(windows); err != nil {
+		return err
+	}
+	m.settingsMu.Lock()
+	defer m.settingsMu.Unlock()
+	if err := updateSettings(m.RootPath, func(st *settings) { st.Schedule = windows }); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.schedule = slices.Clone(windows)
+	m.mu.Unlock()
+
+	m.publishState(StateSchedule)
+	slog.Info("[MOCK] Schedule set", "windows", len(windows))
+	m.checkSchedule()
+	return nil
+}
+
+func (m *MockController) GetLocale() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.locale, nil
+}
+
+func (m *MockController) SetLocale(locale string) error {
+	locale, err := canonicalLocale(locale)
+	if err != nil {
+		return err
+	}
+
+	m.settingsMu.Lock()
+	defer m.settingsMu.Unlock()
+	if err := updateSettings(m.RootPath, func(st *settings) { st.Locale = locale }); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.locale = locale
+	m.mu.Unlock()
+	m.publishState(StateLocale)
+	slog.Info("[MOCK] Locale set", "locale", locale)
+	return nil
+}
+
+func (m *MockController) GetSchedule() ([]RecordingWindow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.schedule), nil
+}
+
+func (m *MockController) runScheduler(stop chan struct{}) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	m.checkSchedule()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.checkSchedule()
+		}
+	}
+}
+
+// checkSchedule starts recording when a window opens and stops the
+// recording it started when the window closes. A window is started once, so
+// stopping by hand inside it sticks.
+func (m *MockController) checkSchedule() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, key := activeWindow(m.schedule, m.now())
+	switch {
+	case w != nil && key != m.schedHandled:
+		m.schedHandled = key
+		if m.isRecording {
+			return
+		}
+		tag := w.Tag
+		if tag == "" {
+			tag = DefaultScheduleTag
+		}
+		if err := m.startLocked(tag); err != nil {
+			slog.Error("[MOCK] Scheduled recording failed to start", "tag", tag, "err", err)
+			m.events.add(RecorderErrored, tag, err.Error())
+			return
+		}
+		m.schedTag = tag
+
+	case w == nil && m.schedTag != "":
+		if m.isRecording && m.recConfig.FilenameTag == m.schedTag {
+			m.cancelRestartLocked()
+			if err := m.stopLocked(nil); err != nil {
+				slog.Error("[MOCK] Scheduled recording failed to stop", "err", err)
+			}
+		}
+		m.schedTag = ""
+	}
+}
+
+// now is the mock device's time. Caller must hold m.mu.
+func (m *MockController) now() time.Time {
+	if m.Clock == nil {
+		return time.Now().Add(m.timeOffset)
+	}
+	return m.Clock.Now().Add(m.timeOffset)
+}
+
+// --- Clock ---
+
+func (m *MockController) GetSystemTime() (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now(), nil
+}
+
+// SetSystemTime leaves the host's clock alone and offsets the mock's own,
+// which names recordings and drives the schedule.
+func (m *MockController) SetSystemTime(t time.Time) error {
+	if err := validateSystemTime(t); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.timeOffset += t.Sub(m.now())
+	offset := m.timeOffset
+	m.mu.Unlock()
+
+	m.publishState(StateRecorder)
+	slog.Info("[MOCK] System time set", "time", t.Format(time.RFC3339), "offset", offset)
+	m.checkSchedule()
+	return nil
+}
+
+// --- Config ---
+
+func (m *MockController) ExportConfig() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return marshalConfig(DeviceConfig{
+		Recorder: recorderDefaults(m.configLocked(), m.autoRestart),
+		Schedule: slices.Clone(m.schedule),
+		Locale:   m.locale,
+		WifiSSID: m.wifiConfig.SSID,
+	})
+}
+
+// ImportConfig validates the whole config before persisting the schedule
+// and locale in one write, then applies the rest.
+func (m *MockController) ImportConfig(data []byte) error {
+	c, err := parseConfig(data)
+	if err != nil {
+		return err
+	}
+
+	m.settingsMu.Lock()
+	defer m.settingsMu.Unlock()
+	err = updateSettings(m.RootPath, func(st *settings) {
+		st.Schedule = c.Schedule
+		st.Locale = c.Locale
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	params := m.configLocked()
+	c.Recorder.apply(&params)
+	m.setConfigLocked(params)
+	m.autoRestart = c.Recorder.AutoRestart
+	if !m.autoRestart {
+		m.cancelRestartLocked()
+	}
+	m.schedule = c.Schedule
+	m.locale = c.Locale
+	m.mu.Unlock()
+
+	m.publishState(StateRecorder)
+	m.publishState(StateSchedule)
+	m.publishState(StateLocale)
+	slog.Info("[MOCK] Config imported")
+	m.checkSchedule()
+	return nil
+}
+
+// --- Sensors ---
+
+// StreamIMU simulates a camera on a tripod: gravity along Z, a slow sway
+// and some sensor noise.
+func (m *MockController) StreamIMU(ctx context.Context) <-chan IMUSample {
+	m.mu.Lock()
+	session, recording := m.recSession, m.isRecording
+	m.mu.Unlock()
+	if !recording {
+		return noIMU()
+	}
+
+	noise := func() float32 { return float32(rand.NormFloat64() * 0.005) }
+	return streamIMU(ctx, func() (IMUSample, bool) {
+		m.mu.Lock()
+		startedAt, ok := m.recStartedAt, m.isRecording && m.recSession == session
+		m.mu.Unlock()
+
+		elapsed := time.Since(startedAt)
+		sway := float32(0.02 * math.Sin(2*math.Pi*elapsed.Seconds()/4))
+		return IMUSample{
+			Millis: uint32(elapsed.Milliseconds()),
+			X:      sway + noise(),
+			Y:      sway/2 + noise(),
+			Z:      1 + noise(),
+		}, ok
+	})
+}
+
+// --- Diagnostics ---
+
+func (m *MockController) GetRuntimeStats() (*RuntimeStats, error) {
+	m.mu.Lock()
+	st := &RuntimeStats{
+		UptimeSecs:        uint32(time.Since(m.initAt).Seconds()),
+		RecordingsCreated: m.recordingsCreated,
+	}
+	if m.isRecording {
+		st.RecorderUptimeSecs = uint32(time.Since(m.recStartedAt).Seconds())
+	}
+	m.mu.Unlock()
+
+	m.io.fill(st)
+	return st, nil
+}
+
+// GetDeviceInfo reports the mock as the hardware, running on the host's OS,
+// with the camera module of the last SimulateDeviceSwap.
+func (m *MockController) GetDeviceInfo() (*DeviceInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info := m.device
+	info.HardwareRevision = "mock"
+	info.SoftwareRevision = cmp.Or(osVersion(), runtime.GOOS)
+	return &info, nil
+}
+
+// mockBenchmark is what the mock reports without real writes, a decent
+// UHS-I card.
+var mockBenchmark = StorageBenchmark{WriteMBps: 42.5, ReadMBps: 88, SizeMB: benchmarkSizeMB, Simulated: true}
+
+// BenchmarkStorage simulates a result, unless real writes are enabled.
+func (m *MockController) BenchmarkStorage() (*StorageBenchmark, error) {
+	m.mu.Lock()
+	recording, real := m.isRecording, m.realMB > 0
+	m.mu.Unlock()
+	if recording {
+		return nil, ErrRecordingInProgress
+	}
+	if real {
+		return m.benchmarkStorage()
+	}
+
+	b := mockBenchmark
+	b.Unix = time.Now().Unix()
+	m.io.recordBenchmark(&b)
+	slog.Info("[MOCK] Storage benchmarked", "write_mbps", b.WriteMBps, "read_mbps", b.ReadMBps)
+	return &b, nil
+}
+
+// --- Thumbnails ---
+
+// Size of the mock's placeholder thumbnails, as the Pi's ffmpeg writes them
+const (
+	mockThumbWidth  = 320
+	mockThumbHeight = 180
+)
+
+// writeMockThumbnail writes the placeholder standing in for a video's
+// first frame.
+func writeMockThumbnail(videoPath, thumbPath string) error {
+	return os.WriteFile(thumbPath, []byte("fake-jpg"), 0644)
+}
+
+// GetThumbnail draws a placeholder gradient at the requested size, since
+// the mock's .jpg sidecars aren't real images. Missing ones are still
+// generated, as the real controllers do.
+func (m *MockController) GetThumbnail(tag string, fileIndex uint32, opts ThumbnailOptions) (*Thumbnail, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	file, err := m.GetRecordingDetails(tag, fileIndex)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.thumbnailStat(file); err != nil {
+		return nil, err
+	}
+
+	w, h := opts.scaledSize(mockThumbWidth, mockThumbHeight)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 96, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.quality()}); err != nil {
+		return nil, err
+	}
+	return &Thumbnail{Width: w, Height: h, Data: buf.Bytes()}, nil
+}
+
+// --- Deletion ---
+
+// PlanDelete lists what a bulk delete would remove, leaving out the tag
+// being recorded into.
+func (m *MockController) PlanDelete(tag string, before int64) (*DeletePlan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.planDeleteLocked(tag, before)
+}
+
+func (m *MockController) DeleteRecordingsBefore(unix int64) (uint32, error) {
+	if unix <= 0 {
+		return 0, fmt.Errorf("invalid cutoff time %d", unix)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	plan, err := m.planDeleteLocked("", unix)
+	if err != nil {
+		return 0, err
+	}
+	return m.deletePlan(plan)
+}
+
+func (m *MockController) DeleteTagRecordings(tag string) (uint32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	plan, err := m.planDeleteLocked(tag, 0)
+	if err != nil {
+		return 0, err
+	}
+	return m.deletePlan(plan)
+}
+
+func (m *MockController) DeleteRecording(tag string, id uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isRecording && tag == m.recConfig.FilenameTag {
+		return ErrTagRecording
+	}
+	return m.FileBrowser.DeleteRecording(tag, id)
+}
+
+func (m *MockController) DeleteTag(tag string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isRecording && tag == m.recConfig.FilenameTag {
+		return 0, ErrTagRecording
+	}
+	return m.FileBrowser.DeleteTag(tag)
+}
+
+func (m *MockController) RepairTag(tag string) (*RepairReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isRecording && tag == m.recConfig.FilenameTag {
+		return nil, ErrTagRecording
+	}
+	return m.FileBrowser.RepairTag(tag)
+}
+
+// planDeleteLocked refuses the tag being recorded into as the deletes do,
+// and leaves it out of a plan for all tags. Caller must hold m.mu.
+func (m *MockController) planDeleteLocked(tag string, before int64) (*DeletePlan, error) {
+	active := m.recConfig.FilenameTag
+	if m.isRecording && tag != "" && tag == active {
+		return nil, ErrTagRecording
+	}
+	plan, err := m.FileBrowser.PlanDelete(tag, before)
+	if err != nil || !m.isRecording {
+		return plan, err
+	}
+
+	// Never touch the active recording's folder
+	plan.Files = slices.DeleteFunc(plan.Files, func(f PlannedFile) bool { return f.Tag == active })
+	plan.Tags = slices.DeleteFunc(plan.Tags, func(t string) bool { return t == active })
+	plan.Count = uint32(len(plan.Files))
+	return plan, nil
+}
@@ -0,0 +1,31 @@
+package hardware
+
+import (
+	"cmp"
+	"strings"
+	"time"
+)
+
+// DefaultFileTimeFormat names recordings vid_<date>_<time>. Leading with the
+// full date keeps the alphabetical listing chronological across days.
+const DefaultFileTimeFormat = "20060102_150405"
+
+// recordingFileName returns the video name for a recording started at t,
+// formatting the time with layout (DefaultFileTimeFormat if empty).
+func recordingFileName(t time.Time, layout string) string {
+	return "vid_" + t.Format(cmp.Or(layout, DefaultFileTimeFormat)) + ".mp4"
+}
+
+// isUndatedName reports whether a video has the vid_HHMMSS.mp4 name older
+// firmware used, which carries no date.
+func isUndatedName(name string) bool {
+	hhmmss, ok := strings.CutPrefix(name, "vid_")
+	if !ok {
+		return false
+	}
+	hhmmss, ok = strings.CutSuffix(hhmmss, ".mp4")
+	if !ok || len(hhmmss) != len("150405") {
+		return false
+	}
+	return strings.Trim(hhmmss, "0123456789") == ""
+}
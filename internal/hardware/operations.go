@@ -0,0 +1,156 @@
+package hardware
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Operation types
+const (
+	OpWifiConnect = "wifi_connect"
+	OpWifiScan    = "wifi_scan"
+	OpManifest    = "manifest"
+	OpChecksum    = "checksum"
+	OpDelete      = "delete"
+	OpTrashPurge  = "trash_purge"
+	OpBenchmark   = "benchmark"
+)
+
+// Operation statuses, reported in OperationEvents
+const (
+	OpStarted  = "started"
+	OpProgress = "progress"
+	OpDone     = "done"
+)
+
+// Operation is a background task in progress.
+type Operation struct {
+	ID          uint32  `json:"id"`
+	Type        string  `json:"type"`
+	Progress    float32 `json:"progress"` // 0 to 1, -1 when unknown
+	StartedUnix int64   `json:"started_unix"`
+}
+
+// OperationEvent reports an operation starting, progressing or ending.
+// Events of concurrent operations are told apart by ID.
+type OperationEvent struct {
+	Op       string  `json:"op"`
+	ID       uint32  `json:"id"`
+	Progress float32 `json:"progress"` // As in Operation
+	Status   string  `json:"status"`
+}
+
+// Operations is a registry of running operations. Each task calls Begin
+// when it starts and End on the returned handle when it's done. The zero
+// value is ready to use.
+type Operations struct {
+	// IDBase is added to IDs, so registries listed together don't overlap
+	IDBase uint32
+
+	mu      sync.Mutex
+	nextID  uint32
+	running map[uint32]*Operation
+	events  EventBus[OperationEvent]
+}
+
+// OperationHandle updates one registered operation.
+type OperationHandle struct {
+	ops     *Operations
+	id      uint32
+	percent int // Last progress published, in whole percent
+}
+
+// Subscribe calls fn with every event of the registry's operations until
+// cancel is called. fn runs on the operation's goroutine and mustn't block.
+func (o *Operations) Subscribe(fn func(OperationEvent)) (cancel func()) {
+	return o.events.Subscribe(fn)
+}
+
+func operationEvent(op *Operation, status string) OperationEvent {
+	return OperationEvent{Op: op.Type, ID: op.ID, Progress: op.Progress, Status: status}
+}
+
+// Begin registers an operation with unknown progress.
+func (o *Operations) Begin(opType string) *OperationHandle {
+	o.mu.Lock()
+	if o.running == nil {
+		o.running = make(map[uint32]*Operation)
+	}
+	o.nextID++
+	op := &Operation{
+		ID:          o.IDBase + o.nextID,
+		Type:        opType,
+		Progress:    -1,
+		StartedUnix: time.Now().Unix(),
+	}
+	o.running[o.nextID] = op
+	h := &OperationHandle{ops: o, id: o.nextID, percent: -1}
+	ev := operationEvent(op, OpStarted)
+	o.mu.Unlock()
+
+	o.events.Publish(ev)
+	return h
+}
+
+// List returns the running operations, oldest first.
+func (o *Operations) List() []Operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	list := make([]Operation, 0, len(o.running))
+	for _, op := range o.running {
+		list = append(list, *op)
+	}
+	slices.SortFunc(list, func(a, b Operation) int { return cmp.Compare(a.ID, b.ID) })
+	return list
+}
+
+// SetProgress records done out of total steps. Subscribers hear of it
+// each time it reaches another whole percent.
+func (h *OperationHandle) SetProgress(done, total int) {
+	if total <= 0 {
+		return
+	}
+	h.ops.mu.Lock()
+	op, ok := h.ops.running[h.id]
+	if !ok {
+		h.ops.mu.Unlock()
+		return
+	}
+	op.Progress = min(float32(done)/float32(total), 1)
+	percent := int(op.Progress * 100)
+	if percent == h.percent {
+		h.ops.mu.Unlock()
+		return
+	}
+	h.percent = percent
+	ev := operationEvent(op, OpProgress)
+	h.ops.mu.Unlock()
+
+	h.ops.events.Publish(ev)
+}
+
+// End unregisters the operation.
+func (h *OperationHandle) End() {
+	h.ops.mu.Lock()
+	op, ok := h.ops.running[h.id]
+	if !ok {
+		h.ops.mu.Unlock()
+		return
+	}
+	delete(h.ops.running, h.id)
+	ev := operationEvent(op, OpDone)
+	h.ops.mu.Unlock()
+
+	h.ops.events.Publish(ev)
+}
+
+func (fb *FileBrowser) GetActiveOperations() ([]Operation, error) {
+	return fb.ops.List(), nil
+}
+
+func (fb *FileBrowser) SubscribeOperations(fn func(OperationEvent)) (cancel func()) {
+	return fb.ops.Subscribe(fn)
+}
@@ -0,0 +1,1403 @@
+//go:build linux
+
+package hardware
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultPiRoot is where the SD card holding recordings is mounted.
+const DefaultPiRoot = "/mnt/sdcard"
+
+// Commands the Pi controller shells out to
+const (
+	defaultRecorderCmd = "libcamera-vid"   // rpicam-vid on newer images
+	defaultStillCmd    = "libcamera-still" // rpicam-still on newer images
+	ffmpegCmd          = "ffmpeg"
+	nmcliCmd           = "nmcli"
+	timedatectlCmd     = "timedatectl"
+)
+
+// powerSupplyDir is where the kernel lists batteries and chargers.
+const powerSupplyDir = "/sys/class/power_supply"
+
+// recorderStopTimeout is how long the recorder gets to finish its file
+// after SIGINT before it's killed.
+const recorderStopTimeout = 5 * time.Second
+
+// iioDevicesDir is where the kernel lists Industrial I/O sensors, such as
+// the accelerometer of an IMU HAT.
+const iioDevicesDir = "/sys/bus/iio/devices"
+
+// Snapshots are sized for a quick look over BLE, not for keeping. The
+// timeout leaves room within a BLE handler's call timeout.
+const (
+	snapshotWidth   = 640
+	snapshotHeight  = 360
+	snapshotQuality = 75
+	snapshotTimeout = 8 * time.Second
+)
+
+// reserveFile holds the space reserved by PreallocateRecording.
+const reserveFile = "reserve"
+
+// PiOptions configure NewPiController. Only RootPath is required.
+type PiOptions struct {
+	RootPath string
+
+	// RecorderCmd is the libcamera video app, empty for libcamera-vid
+	RecorderCmd string
+	// StillCmd is the libcamera still app, empty for libcamera-still
+	StillCmd string
+
+	// RequireMount refuses to record unless RootPath is a mount point, so a
+	// missing SD card doesn't fill the root filesystem.
+	RequireMount bool
+
+	// IMUDevice is the sysfs directory of the accelerometer, empty for the
+	// first IIO device that has one.
+	IMUDevice string
+}
+
+// PiController drives the camera, Wifi and power hardware of the Raspberry
+// Pi. Recording runs libcamera-vid in its signal mode (SIGUSR1 toggles
+// pause), Wifi goes through NetworkManager's nmcli, and battery and disk
+// are read from sysfs and statfs.
+type PiController struct {
+	FileBrowser
+	Disk DiskController
+
+	recorderCmd string
+	stillCmd    string
+	imuDevice   string
+
+	mu sync.Mutex
+
+	// Current recording, proc is nil when idle
+	proc         *exec.Cmd
+	procDone     chan struct{} // Closed when proc exits
+	session      uint64
+	videoPath    string
+	recStartedAt time.Time // Of the current chunk
+	sessionStart time.Time // Of the recording
+	isPaused     bool
+	recMeta      RecordingMetadata
+	baseConfig   *RecorderParameters // Config to restore after a per-recording override
+	override     RecorderParameters  // The override, while baseConfig is set
+
+	autoRestart     bool
+	restartAttempts int
+	restartGen      uint64 // Bumped to cancel a pending restart
+
+	// Recording schedule
+	schedule     []RecordingWindow
+	schedStop    chan struct{}
+	schedTag     string // Tag the scheduler is recording into
+	schedHandled string // Window occurrence already started (or stopped by hand)
+
+	initAt            time.Time
+	recordingsCreated uint32
+	events            recorderLog
+
+	recConfig  RecorderParameters
+	wifiConfig WifiParameters
+	locale     string
+
+	settingsMu sync.Mutex // Serializes updates of the settings file
+}
+
+// NewPiController returns a controller for the Pi hardware. It checks
+// nothing until Init.
+func NewPiController(opts PiOptions) *PiController {
+	p := &PiController{
+		FileBrowser: FileBrowser{
+			RootPath: opts.RootPath,
+		},
+		Disk:        DiskController{RootPath: opts.RootPath, RequireMount: opts.RequireMount},
+		recorderCmd: cmp.Or(opts.RecorderCmd, defaultRecorderCmd),
+		stillCmd:    cmp.Or(opts.StillCmd, defaultStillCmd),
+		imuDevice:   opts.IMUDevice,
+		locale:      DefaultLocale,
+		recConfig: RecorderParameters{
+			FPS:       30,
+			Bitrate:   5000000,
+			ChunkSecs: 300,
+		},
+	}
+	p.events.state = &p.FileBrowser
+	p.makeThumbnail = extractThumbnail
+	return p
+}
+
+func (p *PiController) backend() (string, string) {
+	return BackendPi, p.RootPath
+}
+
+func newPiController(root string) (Controller, error) {
+	if _, err := exec.LookPath(defaultRecorderCmd); err != nil {
+		return nil, err
+	}
+	return NewPiController(PiOptions{RootPath: root, RequireMount: true}), nil
+}
+
+// --- Lifecycle ---
+
+func (p *PiController) Init() error {
+	disk, err := p.Disk.GetDiskStatus()
+	if err != nil {
+		return err
+	}
+	if disk.ReadOnly {
+		slog.Warn("[PI] Storage is read-only, recording will fail until the card is repaired or replaced", "path", p.RootPath)
+	}
+	if err := p.EnsureRoot(); err != nil {
+		return err
+	}
+	for _, name := range []string{p.recorderCmd, nmcliCmd} {
+		if _, err := exec.LookPath(name); err != nil {
+			slog.Warn("[PI] Missing command, related features will fail", "cmd", name, "err", err)
+		}
+	}
+
+	st, err := loadSettings(p.RootPath)
+	if err != nil {
+		slog.Warn("[PI] Ignoring unreadable settings", "err", err)
+	}
+
+	p.mu.Lock()
+	p.initAt = time.Now()
+	p.schedule = st.Schedule
+	p.locale = cmp.Or(st.Locale, DefaultLocale)
+	p.schedStop = make(chan struct{})
+	go p.runScheduler(p.schedStop)
+	p.mu.Unlock()
+
+	slog.Info("[PI] Pi hardware initialized", "root_path", p.RootPath)
+	p.FileBrowser.Start()
+	return nil
+}
+
+func (p *PiController) Close() {
+	p.mu.Lock()
+	p.cancelRestartLocked()
+	if p.proc != nil {
+		if err := p.stopLocked(nil); err != nil {
+			slog.Error("[PI] Failed to stop recorder", "err", err)
+		}
+	}
+	if p.schedStop != nil {
+		close(p.schedStop)
+		p.schedStop = nil
+	}
+	p.mu.Unlock()
+
+	p.FileBrowser.Close()
+	slog.Info("[PI] Pi hardware shutdown")
+}
+
+// --- Connectivity ---
+
+func (p *PiController) SetupWifi(ssid, pwd string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.wifiConfig = WifiParameters{SSID: ssid, Password: pwd}
+	p.publishState(StateWifi)
+	slog.Info("[PI] Wifi credentials saved", "ssid", ssid)
+	return nil
+}
+
+func (p *PiController) ConnectToWifi(ctx context.Context) error {
+	op := p.ops.Begin(OpWifiConnect)
+	defer op.End()
+	defer p.publishState(StateWifi) // Connected or not
+
+	p.mu.Lock()
+	creds := p.wifiConfig
+	p.mu.Unlock()
+	if creds.SSID == "" {
+		return errors.New("no wifi network configured")
+	}
+
+	args := []string{"device", "wifi", "connect", creds.SSID}
+	if creds.Password != "" {
+		args = append(args, "password", creds.Password)
+	}
+	_, err := runCommand(ctx, nmcliCmd, args...)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = ErrWifiTimeout
+	}
+
+	p.mu.Lock()
+	p.wifiConfig.Connected = err == nil
+	p.wifiConfig.LastError = ""
+	if err != nil {
+		p.wifiConfig.LastError = err.Error()
+	}
+	p.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	slog.Info("[PI] Wifi connected", "ssid", creds.SSID)
+	return nil
+}
+
+func (p *PiController) GetWifiDetails() (*WifiParameters, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.wifiConfig
+	return &c, nil
+}
+
+// wifiStatusTimeout bounds the nmcli calls of GetWifiStatus.
+const wifiStatusTimeout = 5 * time.Second
+
+// GetWifiStatus asks NetworkManager for the state of the Wifi device, and
+// once connected its address and signal.
+func (p *PiController) GetWifiStatus() (*WifiLinkStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wifiStatusTimeout)
+	defer cancel()
+
+	out, err := runCommand(ctx, nmcliCmd, "--terse", "--fields", "DEVICE,TYPE,STATE,CONNECTION", "device")
+	if err != nil {
+		return nil, err
+	}
+	var device, state, conn string
+	for line := range strings.Lines(string(out)) {
+		fields := splitTerse(strings.TrimRight(line, "\n"))
+		if len(fields) == 4 && fields[1] == "wifi" {
+			device, state, conn = fields[0], fields[2], fields[3]
+			break
+		}
+	}
+	if device == "" {
+		return nil, errors.New("no wifi device")
+	}
+
+	status := &WifiLinkStatus{SSID: conn}
+	switch {
+	case state == "connected":
+		status.State = WifiConnected
+	case strings.HasPrefix(state, "connecting"): // e.g. "connecting (getting IP configuration)"
+		status.State = WifiConnecting
+		return status, nil
+	default:
+		status.State, status.SSID = WifiDisconnected, ""
+		p.mu.Lock()
+		if p.wifiConfig.LastError != "" {
+			status.State = WifiFailed
+		}
+		p.mu.Unlock()
+		return status, nil
+	}
+
+	// The address and signal are extras: the link is up without them
+	if out, err := runCommand(ctx, nmcliCmd, "--get-values", "IP4.ADDRESS", "device", "show", device); err == nil {
+		// e.g. "192.168.1.5/24", or several separated by " | "
+		status.IPAddress, _, _ = strings.Cut(strings.TrimSpace(string(out)), "/")
+	}
+	if out, err := runCommand(ctx, nmcliCmd, "--terse", "--fields", "IN-USE,SIGNAL",
+		"device", "wifi", "list", "ifname", device, "--rescan", "no"); err == nil {
+		for line := range strings.Lines(string(out)) {
+			fields := splitTerse(strings.TrimRight(line, "\n"))
+			if len(fields) == 2 && fields[0] == "*" {
+				signal, _ := strconv.Atoi(fields[1])
+				status.RSSI = signalToRSSI(signal)
+				break
+			}
+		}
+	}
+	return status, nil
+}
+
+func (p *PiController) ScanWifi(ctx context.Context, found func(WifiNetwork)) error {
+	op := p.ops.Begin(OpWifiScan)
+	defer op.End()
+
+	out, err := runCommand(ctx, nmcliCmd, "--terse", "--fields", "SSID,SIGNAL,SECURITY",
+		"device", "wifi", "list", "--rescan", "yes")
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for line := range strings.Lines(string(out)) {
+		fields := splitTerse(strings.TrimRight(line, "\n"))
+		if len(fields) != 3 || fields[0] == "" || seen[fields[0]] {
+			continue // Hidden network, or another access point of one listed
+		}
+		seen[fields[0]] = true
+
+		signal, _ := strconv.Atoi(fields[1])
+		security := fields[2]
+		if security == "" {
+			security = "open"
+		}
+		found(WifiNetwork{SSID: fields[0], RSSI: signalToRSSI(signal), Security: security})
+	}
+	return ctx.Err()
+}
+
+// splitTerse splits a line of nmcli --terse output, where ':' separates
+// fields and '\' escapes a literal ':' or '\'.
+func splitTerse(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			cur.WriteByte(line[i])
+		case c == ':':
+			fields = append(fields, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(fields, cur.String())
+}
+
+// signalToRSSI converts NetworkManager's 0-100 signal quality to dBm the
+// way NetworkManager derives it.
+func signalToRSSI(signal int) int8 {
+	return int8(min(max(signal, 0), 100)/2 - 100)
+}
+
+// runCommand runs a command and returns its output, with stderr in the
+// error when it fails.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return out, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
+// --- Battery and Storage ---
+
+// GetBatteryStatus reads the first battery the kernel exposes (e.g. a UPS
+// HAT). Without one the Pi is on mains power and reports a full battery
+// on the charger.
+func (p *PiController) GetBatteryStatus() (*BatteryStatus, error) {
+	dir, err := findBattery()
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return &BatteryStatus{Percentage: 100, IsCharging: true}, nil
+	}
+
+	capacity, err := readSysfsInt(filepath.Join(dir, "capacity"))
+	if err != nil {
+		return nil, err
+	}
+	status, _ := os.ReadFile(filepath.Join(dir, "status"))
+
+	st := &BatteryStatus{
+		Percentage: uint8(min(max(capacity, 0), 100)),
+		IsCharging: strings.TrimSpace(string(status)) == "Charging",
+	}
+
+	// Time left needs energy and power, which not every gauge reports
+	now, errNow := readSysfsInt(filepath.Join(dir, "energy_now"))
+	full, errFull := readSysfsInt(filepath.Join(dir, "energy_full"))
+	power, errPower := readSysfsInt(filepath.Join(dir, "power_now"))
+	if errNow == nil && errFull == nil && errPower == nil && power > 0 {
+		left := now
+		if st.IsCharging {
+			left = max(full-now, 0)
+		}
+		st.EstimatedMins = uint16(min(left*60/power, 0xFFFF))
+	}
+	return st, nil
+}
+
+// findBattery returns the sysfs directory of the first battery, or "" if
+// there is none.
+func findBattery() (string, error) {
+	entries, err := os.ReadDir(powerSupplyDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range entries {
+		dir := filepath.Join(powerSupplyDir, e.Name())
+		typ, err := os.ReadFile(filepath.Join(dir, "type"))
+		if err == nil && strings.TrimSpace(string(typ)) == "Battery" {
+			return dir, nil
+		}
+	}
+	return "", nil
+}
+
+func readSysfsInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func readSysfsFloat(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+}
+
+func (p *PiController) GetDiskStatus() (*DiskStatus, error) {
+	st, err := p.Disk.GetDiskStatus()
+	if err != nil {
+		return nil, err
+	}
+	st.TrashMB = p.TrashSizeMB()
+	return st, nil
+}
+
+func (p *PiController) GetStorageInfo() (*StorageInfo, error) {
+	return p.Disk.GetStorageInfo()
+}
+
+// PreallocateRecording checks there is room for the next recording and
+// holds it with a reserve file, released when that recording starts.
+func (p *PiController) PreallocateRecording(estimatedMB uint64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.preallocateLocked(estimatedMB)
+}
+
+// preallocateLocked replaces any previous reservation. Caller must hold p.mu.
+func (p *PiController) preallocateLocked(estimatedMB uint64) error {
+	p.releaseReserveLocked()
+
+	st, err := p.Disk.GetDiskStatus()
+	if err != nil {
+		return err
+	}
+	if estimatedMB > uint64(st.FreeMB) {
+		return fmt.Errorf("%w: need %d MB, %d MB free", ErrInsufficientSpace, estimatedMB, st.FreeMB)
+	}
+
+	dir := filepath.Join(p.RootPath, SettingsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, reserveFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := syscall.Fallocate(int(f.Fd()), 0, 0, int64(estimatedMB)*1024*1024); err != nil {
+		p.releaseReserveLocked()
+		if errors.Is(err, syscall.ENOSPC) {
+			return fmt.Errorf("%w: need %d MB", ErrInsufficientSpace, estimatedMB)
+		}
+		return err
+	}
+	p.publishState(StateDisk)
+	slog.Info("[PI] Recording space reserved", "mb", estimatedMB)
+	return nil
+}
+
+// releaseReserveLocked frees the space held by preallocateLocked. Caller
+// must hold p.mu.
+func (p *PiController) releaseReserveLocked() {
+	err := os.Remove(filepath.Join(p.RootPath, SettingsDir, reserveFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("[PI] Failed to release reserved space", "err", err)
+	}
+}
+
+// --- Recorder Controls ---
+
+func (p *PiController) SetupRecorder(params RecorderParameters) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.setConfigLocked(params)
+	p.publishState(StateRecorder)
+	slog.Info("[PI] Recorder configured",
+		"fps", params.FPS,
+		"bitrate", params.Bitrate,
+		"chunk_secs", params.ChunkSecs)
+	return nil
+}
+
+func (p *PiController) StartRecorder(ctx context.Context, folderTag string) error {
+	return p.StartRecorderWithOptions(ctx, StartOptions{Tag: folderTag})
+}
+
+func (p *PiController) StartRecorderWithOptions(ctx context.Context, opts StartOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A start losing a race mustn't disturb the recording that won it
+	if p.proc != nil {
+		return ErrAlreadyRecording
+	}
+	p.cancelRestartLocked()
+	p.restartAttempts = 0
+	p.schedTag = ""
+
+	if opts.Config != nil {
+		p.overrideConfigLocked(*opts.Config)
+	}
+	if opts.Preallocate {
+		if err := p.preallocateLocked(estimateRecordingMB(p.recConfig.Bitrate, p.recConfig.ChunkSecs)); err != nil {
+			p.restoreConfigLocked()
+			return err
+		}
+	}
+	if err := p.startLocked(opts.Tag); err != nil {
+		p.restoreConfigLocked()
+		return err
+	}
+	p.recMeta.Reason, p.recMeta.Operator = opts.Reason, opts.Operator
+	return nil
+}
+
+// overrideConfigLocked applies a per-recording config until the recording
+// stops. Caller must hold p.mu.
+func (p *PiController) overrideConfigLocked(c RecorderParameters) {
+	base := p.recConfig
+	p.baseConfig, p.override = &base, c
+	p.recConfig = withOverride(p.recConfig, c)
+}
+
+// configLocked returns the recorder config as set, without a per-recording
+// override. Caller must hold p.mu.
+func (p *PiController) configLocked() RecorderParameters {
+	if p.baseConfig != nil {
+		return *p.baseConfig
+	}
+	return p.recConfig
+}
+
+// setConfigLocked replaces the recorder config, keeping the state of the
+// running recording. During a per-recording override it replaces the
+// config restored at stop, and the overridden settings stay until then.
+// Caller must hold p.mu.
+func (p *PiController) setConfigLocked(params RecorderParameters) {
+	params.FilenameTag, params.LastError = p.recConfig.FilenameTag, p.recConfig.LastError
+	if p.baseConfig != nil {
+		base := params
+		p.baseConfig = &base
+		params = withOverride(params, p.override)
+	}
+	p.recConfig = params
+}
+
+// restoreConfigLocked undoes overrideConfigLocked. Caller must hold p.mu.
+func (p *PiController) restoreConfigLocked() {
+	if p.baseConfig == nil {
+		return
+	}
+	tag, lastErr := p.recConfig.FilenameTag, p.recConfig.LastError
+	p.recConfig = *p.baseConfig
+	p.recConfig.FilenameTag, p.recConfig.LastError = tag, lastErr
+	p.baseConfig = nil
+}
+
+// startLocked launches the recorder into a new video of a tag. Caller must
+// hold p.mu.
+func (p *PiController) startLocked(tag string) error {
+	if p.proc != nil {
+		return ErrAlreadyRecording
+	}
+	if err := p.validateTag(tag); err != nil {
+		return err
+	}
+
+	tagBytes, err := p.tagSizeBytes(tag)
+	if err != nil {
+		return err
+	}
+	if quota := uint64(p.recConfig.TagQuotaMB) * 1024 * 1024; quota > 0 && tagBytes >= quota {
+		return ErrTagQuotaExceeded
+	}
+	p.sessionStart = time.Now()
+	p.recMeta = RecordingMetadata{SessionID: newSessionID(p.sessionStart)}
+	if err := p.launchLocked(tag); err != nil {
+		return err
+	}
+
+	p.recConfig.FilenameTag = tag
+	p.recConfig.LastError = ""
+	p.events.add(RecorderStarted, tag, "")
+	slog.Info("[PI] Recording started", "tag", tag, "file", filepath.Base(p.videoPath))
+	return nil
+}
+
+// launchLocked starts the recorder process on a new file. Caller must hold
+// p.mu.
+func (p *PiController) launchLocked(tag string) error {
+	disk, err := p.Disk.GetDiskStatus()
+	if err != nil {
+		return err // Don't record onto the wrong filesystem
+	}
+	if disk.ReadOnly {
+		return fmt.Errorf("%w: %s", ErrStorageReadOnly, p.RootPath)
+	}
+	dir := p.tagPath(tag)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	p.releaseReserveLocked() // The recording needs the space now
+
+	videoPath := filepath.Join(dir, recordingFileName(time.Now(), DefaultFileTimeFormat))
+	cmd := exec.Command(p.recorderCmd, recorderArgs(p.recConfig, videoPath)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	p.proc, p.procDone = cmd, done
+	p.session++
+	p.videoPath = videoPath
+	p.recStartedAt = time.Now()
+	p.isPaused = false
+	p.setActiveRecording(videoPath, p.recMeta.SessionID)
+
+	session := p.session
+	go func() {
+		err := cmd.Wait()
+		close(done)
+		p.recorderExited(cmd, err, &stderr)
+	}()
+	if chunk := time.Duration(p.recConfig.ChunkSecs) * time.Second; chunk > 0 {
+		time.AfterFunc(chunk, func() { p.endChunk(session) })
+	}
+	return nil
+}
+
+// recorderArgs runs libcamera-vid until signalled, muxing to MP4 itself.
+func recorderArgs(c RecorderParameters, videoPath string) []string {
+	return []string{
+		"--timeout", "0",
+		"--nopreview",
+		"--signal", // SIGUSR1 toggles pause
+		"--codec", "libav",
+		"--libav-format", "mp4",
+		"--framerate", strconv.Itoa(int(c.FPS)),
+		"--bitrate", strconv.FormatUint(uint64(c.Bitrate), 10),
+		"--output", videoPath,
+	}
+}
+
+// recorderExited handles the recorder process ending. Exits caused by
+// stopLocked are already dealt with; any other exit is a fault.
+func (p *PiController) recorderExited(cmd *exec.Cmd, err error, stderr *bytes.Buffer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc != cmd {
+		return
+	}
+
+	reason := fmt.Errorf("recorder exited: %v", err)
+	if err == nil {
+		reason = errors.New("recorder exited")
+	}
+	if line := lastLine(stderr.String()); line != "" {
+		reason = fmt.Errorf("%w: %s", reason, line)
+	}
+
+	tag := p.recConfig.FilenameTag
+	p.proc = nil
+	p.finishLocked(reason)
+	slog.Error("[PI] Recorder faulted", "tag", tag, "err", reason)
+	if p.autoRestart {
+		p.scheduleRestartLocked(tag)
+	}
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// endChunk ends the current video when its chunk is complete, rolling over
+// into a new one with auto-restart.
+func (p *PiController) endChunk(session uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc == nil || p.session != session {
+		return
+	}
+	if !p.autoRestart {
+		if err := p.stopLocked(nil); err != nil {
+			slog.Error("[PI] Failed to end chunk", "err", err)
+		}
+		return
+	}
+
+	tag := p.recConfig.FilenameTag
+	fileName := filepath.Base(p.videoPath)
+	p.haltLocked()
+	p.finalizeVideoLocked()
+	if err := p.launchLocked(tag); err != nil {
+		p.recConfig.FilenameTag = ""
+		p.recConfig.LastError = err.Error()
+		p.restoreConfigLocked()
+		p.events.add(RecorderErrored, tag, err.Error())
+		slog.Error("[PI] Failed to roll over recording", "tag", tag, "err", err)
+		return
+	}
+	p.events.add(RecorderRolledOver, tag, fileName)
+	slog.Info("[PI] Recording rolled over", "tag", tag, "file", fileName)
+}
+
+func (p *PiController) StopRecorder() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cancelRestartLocked()
+	return p.stopLocked(nil)
+}
+
+// stopLocked stops the recorder and finalizes its video. Caller must hold
+// p.mu.
+func (p *PiController) stopLocked(reason error) error {
+	if p.proc == nil {
+		return fmt.Errorf("not recording")
+	}
+	p.haltLocked()
+	p.finishLocked(reason)
+	return nil
+}
+
+// haltLocked asks the recorder to finish its file and waits for it to
+// exit. Caller must hold p.mu.
+func (p *PiController) haltLocked() {
+	cmd, done := p.proc, p.procDone
+	p.proc = nil // recorderExited now ignores this process
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		slog.Warn("[PI] Failed to signal recorder", "err", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(recorderStopTimeout):
+		slog.Warn("[PI] Recorder didn't stop, killing it")
+		_ = cmd.Process.Kill()
+		<-done
+	}
+}
+
+// finishLocked records the end of the recording once the recorder has
+// exited. Caller must hold p.mu.
+func (p *PiController) finishLocked(reason error) {
+	tag := p.recConfig.FilenameTag
+	fileName := p.finalizeVideoLocked()
+
+	p.isPaused = false
+	p.restoreConfigLocked()
+	p.recConfig.FilenameTag = ""
+	if reason != nil {
+		p.recConfig.LastError = reason.Error()
+		p.events.add(RecorderErrored, tag, reason.Error())
+	}
+	p.events.add(RecorderStopped, tag, fileName)
+	slog.Info("[PI] Recording stopped", "tag", tag, "file", fileName)
+}
+
+// finalizeVideoLocked writes the sidecars of the finished video and
+// returns its name. Caller must hold p.mu.
+func (p *PiController) finalizeVideoLocked() string {
+	videoPath := p.videoPath
+	p.setActiveRecording("", "")
+	p.videoPath = ""
+	p.recordingsCreated++
+
+	if err := writeMetadata(videoPath, p.recMeta); err != nil {
+		slog.Warn("[PI] Failed to write recording metadata", "err", err)
+	}
+	go writeThumbnail(videoPath)
+	p.publishState(StateDisk)
+	return filepath.Base(videoPath)
+}
+
+// writeThumbnail grabs the first frame of a video as its .jpg sidecar.
+func writeThumbnail(videoPath string) {
+	thumbPath := strings.TrimSuffix(videoPath, ".mp4") + ".jpg"
+	if err := extractThumbnail(videoPath, thumbPath); err != nil {
+		slog.Warn("[PI] Failed to write thumbnail", "file", filepath.Base(videoPath), "err", err)
+	}
+}
+
+func extractThumbnail(videoPath, thumbPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := runCommand(ctx, ffmpegCmd, "-loglevel", "error", "-y",
+		"-i", videoPath, "-frames:v", "1", "-vf", "scale=320:-2", thumbPath)
+	return err
+}
+
+func (p *PiController) PauseRecorder() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc == nil {
+		return fmt.Errorf("not recording")
+	}
+	if p.isPaused {
+		return nil
+	}
+	if err := p.proc.Process.Signal(syscall.SIGUSR1); err != nil {
+		return err
+	}
+	p.isPaused = true
+	p.events.add(RecorderPaused, p.recConfig.FilenameTag, "")
+	slog.Info("[PI] Recording paused", "tag", p.recConfig.FilenameTag)
+	return nil
+}
+
+func (p *PiController) ResumeRecorder() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc == nil || !p.isPaused {
+		return fmt.Errorf("not paused")
+	}
+	if err := p.proc.Process.Signal(syscall.SIGUSR1); err != nil {
+		return err
+	}
+	p.isPaused = false
+	p.events.add(RecorderResumed, p.recConfig.FilenameTag, "")
+	slog.Info("[PI] Recording resumed", "tag", p.recConfig.FilenameTag)
+	return nil
+}
+
+func (p *PiController) GetRecorderInfo() (*RecorderParameters, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c := p.recConfig
+	c.AutoRestart = p.autoRestart
+	c.Paused = p.proc != nil && p.isPaused
+	c.StartedUnix = 0 // Ignored by SetupRecorder
+	if p.proc != nil {
+		c.StartedUnix = p.sessionStart.Unix()
+	}
+	return &c, nil
+}
+
+func (p *PiController) GetCurrentSessionID() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc == nil {
+		return "", nil
+	}
+	return p.recMeta.SessionID, nil
+}
+
+// GetRecorderLiveStats reports the configured rate while recording:
+// libcamera-vid doesn't expose encoder statistics, so the counters stay 0.
+func (p *PiController) GetRecorderLiveStats() (*RecorderLiveStats, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc == nil || p.isPaused {
+		return &RecorderLiveStats{}, nil
+	}
+	return &RecorderLiveStats{FPS: float32(p.recConfig.FPS), Bitrate: p.recConfig.Bitrate}, nil
+}
+
+func (p *PiController) GetRecorderEvents(since int64) ([]RecorderEvent, error) {
+	return p.events.since(since), nil
+}
+
+// --- Auto-restart ---
+
+func (p *PiController) SetAutoRestart(enabled bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.autoRestart = enabled
+	if !enabled {
+		p.cancelRestartLocked()
+	}
+	p.publishState(StateRecorder)
+	slog.Info("[PI] Recorder auto-restart set", "enabled", enabled)
+	return nil
+}
+
+// scheduleRestartLocked restarts the recorder after a fault, backing off
+// like the mock. Caller must hold p.mu.
+func (p *PiController) scheduleRestartLocked(tag string) {
+	if time.Since(p.recStartedAt) >= restartResetAfter {
+		p.restartAttempts = 0
+	}
+	delay := restartMaxDelay
+	if p.restartAttempts < 6 {
+		delay = min(restartBaseDelay<<p.restartAttempts, restartMaxDelay)
+	}
+	p.restartAttempts++
+
+	p.restartGen++
+	gen := p.restartGen
+	time.AfterFunc(delay, func() { p.restart(gen, tag) })
+	slog.Warn("[PI] Recorder restart scheduled", "tag", tag, "delay", delay, "attempt", p.restartAttempts)
+}
+
+func (p *PiController) restart(gen uint64, tag string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if gen != p.restartGen || p.proc != nil || !p.autoRestart {
+		return
+	}
+	if err := p.startLocked(tag); err != nil {
+		slog.Error("[PI] Recorder restart failed", "tag", tag, "err", err)
+		p.events.add(RecorderErrored, tag, err.Error())
+		if !errors.Is(err, ErrTagQuotaExceeded) {
+			p.scheduleRestartLocked(tag)
+		}
+	}
+}
+
+// cancelRestartLocked drops a pending restart. Caller must hold p.mu.
+func (p *PiController) cancelRestartLocked() {
+	p.restartGen++
+}
+
+// --- Triggers ---
+
+func (p *PiController) TriggerRecording(reason string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.proc != nil {
+		return ErrAlreadyRecording
+	}
+	tag := triggerTag(reason)
+	p.cancelRestartLocked()
+	p.schedTag = ""
+	if err := p.startLocked(tag); err != nil {
+		return err
+	}
+	p.recMeta.Reason = reason
+
+	// p.session counts chunks; the trigger lasts across rollovers
+	session := p.recMeta.SessionID
+	time.AfterFunc(TriggerRecordDuration, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.proc != nil && p.recMeta.SessionID == session {
+			if err := p.stopLocked(nil); err != nil {
+				slog.Error("[PI] Failed to end triggered recording", "err", err)
+			}
+		}
+	})
+
+	slog.Info("[PI] Recording triggered", "reason", reason, "tag", tag)
+	return nil
+}
+
+// --- Snapshot ---
+
+// CaptureSnapshot runs the still app with its output on stdout. Its one
+// second timeout lets auto exposure settle before the capture.
+func (p *PiController) CaptureSnapshot() ([]byte, error) {
+	p.mu.Lock()
+	recording := p.proc != nil
+	p.mu.Unlock()
+	if recording {
+		return nil, ErrRecordingInProgress
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	data, err := runCommand(ctx, p.stillCmd, "--nopreview", "--timeout", "1000",
+		"--width", strconv.Itoa(snapshotWidth), "--height", strconv.Itoa(snapshotHeight),
+		"--quality", strconv.Itoa(snapshotQuality), "--encoding", "jpg", "--output", "-")
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("[PI] Snapshot captured", "bytes", len(data))
+	return data, nil
+}
+
+// --- Schedule ---
+
+func (p *PiController) SetSchedule(windows []RecordingWindow) error {
+	if err := validateSchedule(windows); err != nil {
+		return err
+	}
+
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	if err := updateSettings(p.RootPath, func(st *settings) { st.Schedule = windows }); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.schedule = slices.Clone(windows)
+	p.mu.Unlock()
+
+	p.publishState(StateSchedule)
+	slog.Info("[PI] Schedule set", "windows", len(windows))
+	p.checkSchedule()
+	return nil
+}
+
+func (p *PiController) GetSchedule() ([]RecordingWindow, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.schedule), nil
+}
+
+func (p *PiController) runScheduler(stop chan struct{}) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	p.checkSchedule()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.checkSchedule()
+		}
+	}
+}
+
+// checkSchedule starts and stops scheduled recordings, as the mock does.
+func (p *PiController) checkSchedule() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w, key := activeWindow(p.schedule, time.Now())
+	switch {
+	case w != nil && key != p.schedHandled:
+		p.schedHandled = key
+		if p.proc != nil {
+			return
+		}
+		tag := cmp.Or(w.Tag, DefaultScheduleTag)
+		if err := p.startLocked(tag); err != nil {
+			slog.Error("[PI] Scheduled recording failed to start", "tag", tag, "err", err)
+			p.events.add(RecorderErrored, tag, err.Error())
+			return
+		}
+		p.schedTag = tag
+
+	case w == nil && p.schedTag != "":
+		if p.proc != nil && p.recConfig.FilenameTag == p.schedTag {
+			p.cancelRestartLocked()
+			if err := p.stopLocked(nil); err != nil {
+				slog.Error("[PI] Scheduled recording failed to stop", "err", err)
+			}
+		}
+		p.schedTag = ""
+	}
+}
+
+// --- Locale ---
+
+func (p *PiController) GetLocale() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.locale, nil
+}
+
+func (p *PiController) SetLocale(locale string) error {
+	locale, err := canonicalLocale(locale)
+	if err != nil {
+		return err
+	}
+
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	if err := updateSettings(p.RootPath, func(st *settings) { st.Locale = locale }); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.locale = locale
+	p.mu.Unlock()
+	p.publishState(StateLocale)
+	slog.Info("[PI] Locale set", "locale", locale)
+	return nil
+}
+
+// --- Clock ---
+
+func (p *PiController) GetSystemTime() (time.Time, error) {
+	return time.Now(), nil
+}
+
+// SetSystemTime sets the kernel clock with settimeofday, which needs
+// CAP_SYS_TIME, falling back on timedatectl (through polkit) without it.
+func (p *PiController) SetSystemTime(t time.Time) error {
+	if err := validateSystemTime(t); err != nil {
+		return err
+	}
+
+	tv := syscall.NsecToTimeval(t.UnixNano())
+	if err := syscall.Settimeofday(&tv); err != nil {
+		if !errors.Is(err, syscall.EPERM) {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := runCommand(ctx, timedatectlCmd, "set-time", t.Local().Format(time.DateTime)); err != nil {
+			return err
+		}
+	}
+
+	p.publishState(StateRecorder)
+	slog.Info("[PI] System time set", "time", t.Format(time.RFC3339))
+	p.checkSchedule()
+	return nil
+}
+
+// --- Config ---
+
+func (p *PiController) ExportConfig() ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return marshalConfig(DeviceConfig{
+		Recorder: recorderDefaults(p.configLocked(), p.autoRestart),
+		Schedule: slices.Clone(p.schedule),
+		Locale:   p.locale,
+		WifiSSID: p.wifiConfig.SSID,
+	})
+}
+
+// ImportConfig validates the whole config before persisting the schedule
+// and locale in one write, then applies the rest.
+func (p *PiController) ImportConfig(data []byte) error {
+	c, err := parseConfig(data)
+	if err != nil {
+		return err
+	}
+
+	p.settingsMu.Lock()
+	defer p.settingsMu.Unlock()
+	err = updateSettings(p.RootPath, func(st *settings) {
+		st.Schedule = c.Schedule
+		st.Locale = c.Locale
+	})
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	params := p.configLocked()
+	c.Recorder.apply(&params)
+	p.setConfigLocked(params)
+	p.autoRestart = c.Recorder.AutoRestart
+	if !p.autoRestart {
+		p.cancelRestartLocked()
+	}
+	p.schedule = c.Schedule
+	p.locale = c.Locale
+	p.mu.Unlock()
+
+	p.publishState(StateRecorder)
+	p.publishState(StateSchedule)
+	p.publishState(StateLocale)
+	slog.Info("[PI] Config imported")
+	p.checkSchedule()
+	return nil
+}
+
+// BenchmarkStorage measures the SD card. The recorder must be idle, both
+// for a fair reading and not to starve it of bandwidth.
+func (p *PiController) BenchmarkStorage() (*StorageBenchmark, error) {
+	p.mu.Lock()
+	recording := p.proc != nil
+	p.mu.Unlock()
+	if recording {
+		return nil, ErrRecordingInProgress
+	}
+
+	b, err := p.benchmarkStorage()
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("[PI] Storage benchmarked", "write_mbps", b.WriteMBps, "read_mbps", b.ReadMBps)
+	return b, nil
+}
+
+// --- Deletion ---
+
+func (p *PiController) DeleteRecordingsBefore(unix int64) (uint32, error) {
+	if unix <= 0 {
+		return 0, fmt.Errorf("invalid cutoff time %d", unix)
+	}
+	plan, err := p.PlanDelete("", unix) // Leaves out the active recording
+	if err != nil {
+		return 0, err
+	}
+	return p.deletePlan(plan)
+}
+
+// PlanDelete refuses the tag being recorded into, as the deletes do.
+func (p *PiController) PlanDelete(tag string, before int64) (*DeletePlan, error) {
+	if tag != "" && p.isRecordingInto(tag) {
+		return nil, ErrTagRecording
+	}
+	return p.FileBrowser.PlanDelete(tag, before)
+}
+
+func (p *PiController) DeleteTagRecordings(tag string) (uint32, error) {
+	plan, err := p.PlanDelete(tag, 0)
+	if err != nil {
+		return 0, err
+	}
+	return p.deletePlan(plan)
+}
+
+func (p *PiController) DeleteRecording(tag string, id uint32) error {
+	if p.isRecordingInto(tag) {
+		return ErrTagRecording
+	}
+	return p.FileBrowser.DeleteRecording(tag, id)
+}
+
+func (p *PiController) DeleteTag(tag string) (uint64, error) {
+	if p.isRecordingInto(tag) {
+		return 0, ErrTagRecording
+	}
+	return p.FileBrowser.DeleteTag(tag)
+}
+
+func (p *PiController) RepairTag(tag string) (*RepairReport, error) {
+	if p.isRecordingInto(tag) {
+		return nil, ErrTagRecording
+	}
+	return p.FileBrowser.RepairTag(tag)
+}
+
+func (p *PiController) isRecordingInto(tag string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.proc != nil && p.recConfig.FilenameTag == tag
+}
+
+// --- Sensors ---
+
+// StreamIMU polls the accelerometer through the kernel's IIO interface,
+// converting its raw readings with the device's scale (m/s² per unit).
+func (p *PiController) StreamIMU(ctx context.Context) <-chan IMUSample {
+	p.mu.Lock()
+	session, startedAt, recording := p.session, p.recStartedAt, p.proc != nil
+	p.mu.Unlock()
+	if !recording {
+		return noIMU()
+	}
+
+	dev, err := p.findAccelerometer()
+	if err != nil || dev == "" {
+		slog.Warn("[PI] No accelerometer", "err", err)
+		return noIMU()
+	}
+
+	scale, err := readSysfsFloat(filepath.Join(dev, "in_accel_scale"))
+	if err != nil {
+		scale = 1 // Already in m/s²
+	}
+	return streamIMU(ctx, func() (IMUSample, bool) {
+		p.mu.Lock()
+		ok := p.proc != nil && p.session == session
+		p.mu.Unlock()
+
+		var axes [3]float32
+		for i, axis := range []string{"x", "y", "z"} {
+			raw, err := readSysfsInt(filepath.Join(dev, "in_accel_"+axis+"_raw"))
+			if err != nil {
+				slog.Warn("[PI] Accelerometer read failed", "err", err)
+				return IMUSample{}, false
+			}
+			axes[i] = float32(float64(raw) * scale / standardGravity)
+		}
+		return IMUSample{
+			Millis: uint32(time.Since(startedAt).Milliseconds()),
+			X:      axes[0],
+			Y:      axes[1],
+			Z:      axes[2],
+		}, ok
+	})
+}
+
+// findAccelerometer returns the configured IMU device, or else the first
+// IIO device with an X axis accelerometer, or "" if there is none.
+func (p *PiController) findAccelerometer() (string, error) {
+	if p.imuDevice != "" {
+		return p.imuDevice, nil
+	}
+	entries, err := os.ReadDir(iioDevicesDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, e := range entries {
+		dir := filepath.Join(iioDevicesDir, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, "in_accel_x_raw")); err == nil {
+			return dir, nil
+		}
+	}
+	return "", nil
+}
+
+// --- Diagnostics ---
+
+func (p *PiController) GetRuntimeStats() (*RuntimeStats, error) {
+	p.mu.Lock()
+	st := &RuntimeStats{
+		UptimeSecs:        uint32(time.Since(p.initAt).Seconds()),
+		RecordingsCreated: p.recordingsCreated,
+	}
+	if p.proc != nil {
+		st.RecorderUptimeSecs = uint32(time.Since(p.recStartedAt).Seconds())
+	}
+	p.mu.Unlock()
+
+	p.io.fill(st)
+	return st, nil
+}
+
+func (p *PiController) GetDeviceInfo() (*DeviceInfo, error) {
+	return &DeviceInfo{
+		HardwareRevision: cmp.Or(boardModel(), "unknown"),
+		SoftwareRevision: cmp.Or(osVersion(), "unknown"),
+	}, nil
+}
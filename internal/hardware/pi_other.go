@@ -0,0 +1,12 @@
+//go:build !linux
+
+package hardware
+
+import "errors"
+
+// DefaultPiRoot is where the SD card holding recordings is mounted.
+const DefaultPiRoot = "/mnt/sdcard"
+
+func newPiController(root string) (Controller, error) {
+	return nil, errors.New("the Pi controller only runs on Linux")
+}
@@ -0,0 +1,68 @@
+package hardware
+
+import (
+	"sync"
+	"time"
+)
+
+// Recorder event types
+const (
+	RecorderStarted    = "started"
+	RecorderStopped    = "stopped"
+	RecorderRolledOver = "rolled_over"
+	RecorderPaused     = "paused"
+	RecorderResumed    = "resumed"
+	RecorderErrored    = "error"
+)
+
+// recorderLogSize is how many recorder events are kept.
+const recorderLogSize = 64
+
+type RecorderEvent struct {
+	Type   string `json:"type"`
+	Unix   int64  `json:"unix"`
+	Tag    string `json:"tag,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// recorderLog is a bounded, in-memory history of recorder events so a
+// reconnecting app can catch up on what happened while it was away.
+type recorderLog struct {
+	mu     sync.Mutex
+	events []RecorderEvent
+
+	// state, if set, hears of every event as a StateRecorder change
+	state *FileBrowser
+}
+
+func (l *recorderLog) add(typ, tag, detail string) {
+	l.mu.Lock()
+	if len(l.events) == recorderLogSize {
+		l.events = append(l.events[:0], l.events[1:]...)
+	}
+	l.events = append(l.events, RecorderEvent{
+		Type:   typ,
+		Unix:   time.Now().Unix(),
+		Tag:    tag,
+		Detail: detail,
+	})
+	l.mu.Unlock()
+
+	if l.state != nil {
+		l.state.publishState(StateRecorder)
+	}
+}
+
+// since returns the events at or after the given unix time, oldest first.
+func (l *recorderLog) since(unix int64) []RecorderEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := []RecorderEvent{}
+	for _, ev := range l.events {
+		if ev.Unix >= unix {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
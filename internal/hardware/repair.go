@@ -0,0 +1,90 @@
+package hardware
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// RepairReport lists what RepairTag removed from a tag folder.
+type RepairReport struct {
+	Tag        string   `json:"tag"`
+	Removed    []string `json:"removed"`
+	FreedBytes uint64   `json:"freed_bytes"`
+}
+
+// RepairTag removes the leftovers of interrupted recordings from a tag:
+// zero-byte videos and sidecars whose .mp4 is missing. They are deleted
+// outright rather than trashed since there is nothing to restore.
+func (fb *FileBrowser) RepairTag(tag string) (*RepairReport, error) {
+	return fb.repairTag(tag, false)
+}
+
+// PlanRepair reports what RepairTag would remove, without removing it.
+func (fb *FileBrowser) PlanRepair(tag string) (*RepairReport, error) {
+	return fb.repairTag(tag, true)
+}
+
+func (fb *FileBrowser) repairTag(tag string, dryRun bool) (*RepairReport, error) {
+	if err := fb.validateTag(tag); err != nil {
+		return nil, err
+	}
+	dir := fb.tagPath(tag)
+	entries, err := fb.readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	videos := make(map[string]bool) // Base name -> has content
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !strings.HasSuffix(e.Name(), ".mp4") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		videos[strings.TrimSuffix(e.Name(), ".mp4")] = info.Size() > 0
+	}
+
+	report := &RepairReport{Tag: tag, Removed: []string{}}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		name := e.Name()
+		ext := filepath.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+
+		var broken bool
+		switch {
+		case ext == ".mp4":
+			broken = !videos[base]
+		case slices.Contains(sidecarExts, ext):
+			broken = !videos[base] // Orphaned, or belongs to an empty video
+		}
+		if !broken {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return report, err
+			}
+		}
+		report.Removed = append(report.Removed, name)
+		report.FreedBytes += uint64(info.Size())
+	}
+
+	if len(report.Removed) > 0 && !dryRun {
+		fb.publishState(StateDisk)
+		slog.Info("Repaired tag", "tag", tag, "removed", len(report.Removed), "freed_bytes", report.FreedBytes)
+	}
+	return report, nil
+}
@@ -0,0 +1,93 @@
+package hardware
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// DefaultScheduleTag is recorded into when a window doesn't name a tag.
+const DefaultScheduleTag = "Scheduled"
+
+// RecordingWindow is a daily period during which the recorder runs, in the
+// device's local time. A window ending before it starts runs overnight.
+type RecordingWindow struct {
+	Start string         `json:"start"`          // "HH:MM"
+	End   string         `json:"end"`            // "HH:MM"
+	Days  []time.Weekday `json:"days,omitempty"` // Days the window starts on (0 = Sunday), empty for every day
+	Tag   string         `json:"tag,omitempty"`  // DefaultScheduleTag when empty
+}
+
+// validateSchedule checks every window parses and isn't empty.
+func validateSchedule(windows []RecordingWindow) error {
+	for i, w := range windows {
+		start, end, err := w.bounds()
+		if err != nil {
+			return fmt.Errorf("window %d: %w", i, err)
+		}
+		if start == end {
+			return fmt.Errorf("window %d: start and end are both %s", i, w.Start)
+		}
+		for _, d := range w.Days {
+			if d < time.Sunday || d > time.Saturday {
+				return fmt.Errorf("window %d: invalid day %d", i, d)
+			}
+		}
+	}
+	return nil
+}
+
+// bounds returns the window's start and end as offsets from midnight.
+func (w RecordingWindow) bounds() (start, end time.Duration, err error) {
+	if start, err = parseClockTime(w.Start); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClockTime(w.End); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+func parseClockTime(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// occurrence returns when the occurrence of the window containing now
+// started, if now is inside one.
+func (w RecordingWindow) occurrence(now time.Time) (time.Time, bool) {
+	start, end, err := w.bounds()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// An overnight window may have started yesterday
+	for back := range 2 {
+		day := time.Date(now.Year(), now.Month(), now.Day()-back, 0, 0, 0, 0, now.Location())
+		if len(w.Days) > 0 && !slices.Contains(w.Days, day.Weekday()) {
+			continue
+		}
+		from, to := day.Add(start), day.Add(end)
+		if end < start {
+			to = to.Add(24 * time.Hour)
+		}
+		if !now.Before(from) && now.Before(to) {
+			return from, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// activeWindow returns the first window now falls in, and a key naming that
+// occurrence of it.
+func activeWindow(windows []RecordingWindow, now time.Time) (*RecordingWindow, string) {
+	for i := range windows {
+		if from, ok := windows[i].occurrence(now); ok {
+			return &windows[i], fmt.Sprintf("%d@%d", i, from.Unix())
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,87 @@
+package hardware
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// sessionTimeLayout leads a session id, see newSessionID.
+const sessionTimeLayout = "20060102T150405Z"
+
+// SessionInfo is one recording as the user started it, however many chunks
+// it was split into.
+type SessionInfo struct {
+	ID         string `json:"id,omitempty"` // Empty for a video recorded before session ids, listed alone
+	StartUnix  int64  `json:"start_unix"`
+	Chunks     uint32 `json:"chunks"`
+	SizeMB     uint32 `json:"size_mb"`
+	FirstID    uint32 `json:"first_id"` // Recording id of the first chunk
+	InProgress bool   `json:"in_progress,omitempty"`
+}
+
+// sessionStart reads the start time out of a session id.
+func sessionStart(id string) (time.Time, bool) {
+	if len(id) < len(sessionTimeLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(sessionTimeLayout, id[:len(sessionTimeLayout)])
+	return t, err == nil
+}
+
+// ListSessions groups the files of a tag by session, in the order of their
+// first chunks (alphabetical, so chronological).
+func (fb *FileBrowser) ListSessions(tag string) ([]SessionInfo, error) {
+	if err := fb.validateTag(tag); err != nil {
+		return nil, err
+	}
+	tagPath := fb.tagPath(tag)
+
+	files, err := fb.getSortedFiles(tagPath)
+	if err != nil {
+		return nil, fmt.Errorf("tag '%s' not found or empty", tag)
+	}
+
+	var sessions []SessionInfo
+	var sizes []int64
+	index := make(map[string]int) // Position in sessions by id
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil {
+			continue // Deleted since the folder was read
+		}
+		path := filepath.Join(tagPath, f.Name())
+		md, err := readMetadata(path)
+		if err != nil {
+			slog.Warn("unreadable recording metadata", "file", path, "err", err)
+		}
+		id := cmp.Or(md.SessionID, fb.activeSession(path))
+
+		i, ok := index[id]
+		if !ok || id == "" {
+			start := info.ModTime()
+			if t, ok := sessionStart(id); ok {
+				start = t
+			}
+			i = len(sessions)
+			sessions = append(sessions, SessionInfo{
+				ID:        id,
+				StartUnix: start.Unix(),
+				FirstID:   recordingID(f.Name()),
+			})
+			sizes = append(sizes, 0)
+			if id != "" {
+				index[id] = i
+			}
+		}
+		sessions[i].Chunks++
+		sizes[i] += info.Size()
+		sessions[i].InProgress = sessions[i].InProgress || fb.isActive(path)
+	}
+	for i := range sessions {
+		sessions[i].SizeMB = uint32(sizes[i] / 1024 / 1024)
+	}
+	return sessions, nil
+}
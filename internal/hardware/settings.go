@@ -0,0 +1,65 @@
+package hardware
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// SettingsDir is the hidden folder under RootPath holding settings that
+// survive a restart.
+const SettingsDir = ".blueowl"
+
+const settingsFile = "settings.json"
+
+// settings is what the controller persists in SettingsDir.
+type settings struct {
+	Schedule []RecordingWindow `json:"schedule,omitempty"`
+	Locale   string            `json:"locale,omitempty"`
+}
+
+// loadSettings reads the persisted settings, returning zero settings if
+// none were saved yet.
+func loadSettings(root string) (settings, error) {
+	var st settings
+	data, err := os.ReadFile(filepath.Join(root, SettingsDir, settingsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	return st, json.Unmarshal(data, &st)
+}
+
+// updateSettings changes the persisted settings through fn, keeping the
+// fields it doesn't touch.
+func updateSettings(root string, fn func(*settings)) error {
+	st, err := loadSettings(root)
+	if err != nil {
+		return err
+	}
+	fn(&st)
+	return saveSettings(root, st)
+}
+
+// saveSettings replaces the persisted settings. The file is written aside
+// and renamed so a power cut never leaves it half written.
+func saveSettings(root string, st settings) error {
+	dir := filepath.Join(root, SettingsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, settingsFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, settingsFile))
+}
@@ -0,0 +1,45 @@
+package hardware
+
+import "sync"
+
+// flightGroup deduplicates concurrent calls with the same key: callers that
+// arrive while a call is running wait for it and share its result. Results
+// are shared, so callers must not modify them. The zero value is ready to use.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	wg  sync.WaitGroup
+	val T
+	err error
+}
+
+// Do runs fn for key, or waits for the in-flight call with the same key.
+func (g *flightGroup[T]) Do(key string, fn func() (T, error)) (T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+
+	c := &flightCall[T]{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
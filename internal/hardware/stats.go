@@ -0,0 +1,64 @@
+package hardware
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// diskReadWindow is how many recent directory reads are averaged.
+const diskReadWindow = 16
+
+// ioStats records how long recent directory reads took, and the last
+// storage benchmark.
+type ioStats struct {
+	mu     sync.Mutex
+	reads  uint32
+	recent [diskReadWindow]time.Duration
+	bench  *StorageBenchmark
+}
+
+func (s *ioStats) recordBenchmark(b *StorageBenchmark) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bench = b
+}
+
+func (s *ioStats) record(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recent[s.reads%diskReadWindow] = d
+	s.reads++
+}
+
+// fill copies the read counters into st.
+func (s *ioStats) fill(st *RuntimeStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bench != nil {
+		st.StorageWriteMBps = s.bench.WriteMBps
+		st.StorageReadMBps = s.bench.ReadMBps
+	}
+
+	st.DiskReads = s.reads
+	if s.reads == 0 {
+		return
+	}
+
+	n := min(s.reads, diskReadWindow)
+	var total time.Duration
+	for _, d := range s.recent[:n] {
+		total += d
+	}
+	st.LastDiskReadUs = uint32(s.recent[(s.reads-1)%diskReadWindow].Microseconds())
+	st.AvgDiskReadUs = uint32((total / time.Duration(n)).Microseconds())
+}
+
+// readDir is os.ReadDir, timed into the browser's I/O stats.
+func (fb *FileBrowser) readDir(path string) ([]os.DirEntry, error) {
+	start := time.Now()
+	entries, err := os.ReadDir(path)
+	fb.io.record(time.Since(start))
+	return entries, err
+}
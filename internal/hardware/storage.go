@@ -0,0 +1,148 @@
+package hardware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrStorageReadOnly refuses to record onto a read-only filesystem, such as
+// a card with its lock switch on or one the kernel remounted after errors.
+var ErrStorageReadOnly = errors.New("storage is mounted read-only")
+
+// Where the kernel lists mounts and udev links volume labels
+const (
+	procMounts  = "/proc/mounts"
+	diskByLabel = "/dev/disk/by-label"
+)
+
+// StorageInfo describes the filesystem holding the recordings.
+type StorageInfo struct {
+	FSType     string `json:"fs_type"` // As the kernel names it, e.g. "exfat" or "ext4"
+	Label      string `json:"label,omitempty"`
+	Device     string `json:"device"`
+	MountPoint string `json:"mount_point"`
+	ReadOnly   bool   `json:"read_only"`
+}
+
+// GetStorageInfo finds the mount holding RootPath in /proc/mounts.
+func (d DiskController) GetStorageInfo() (*StorageInfo, error) {
+	root, err := filepath.Abs(d.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	f, err := os.Open(procMounts)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := findMount(f, root)
+	if err != nil {
+		return nil, err
+	}
+	info.Label = volumeLabel(info.Device)
+	info.ReadOnly = info.ReadOnly || isReadOnly(root)
+	return info, nil
+}
+
+// findMount returns the mount of a mounts table that path is on: the
+// deepest mount point holding it, and the last of those, as later mounts
+// stack over earlier ones.
+func findMount(r io.Reader, path string) (*StorageInfo, error) {
+	var best *StorageInfo
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		// device mountpoint fstype options dump pass
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		mount := unescapeMount(fields[1])
+		if !pathWithin(path, mount) || (best != nil && len(mount) < len(best.MountPoint)) {
+			continue
+		}
+		best = &StorageInfo{
+			FSType:     fields[2],
+			Device:     unescapeMount(fields[0]),
+			MountPoint: mount,
+			ReadOnly:   slices.Contains(strings.Split(fields[3], ","), "ro"),
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: no mount holds %s", ErrStorageNotMounted, path)
+	}
+	return best, nil
+}
+
+// pathWithin reports whether path is dir or below it.
+func pathWithin(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// unescapeMount decodes the octal escapes (\040 for a space, ...) of a
+// mounts table field.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// volumeLabel looks up a device's label among udev's by-label links, "" if
+// it has none.
+func volumeLabel(device string) string {
+	entries, err := os.ReadDir(diskByLabel)
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved // e.g. a /dev/disk/by-uuid link
+	}
+	for _, e := range entries {
+		target, err := filepath.EvalSymlinks(filepath.Join(diskByLabel, e.Name()))
+		if err == nil && target == device {
+			return unescapeLabel(e.Name())
+		}
+	}
+	return ""
+}
+
+// unescapeLabel decodes udev's \xNN escapes (\x20 for a space, ...).
+func unescapeLabel(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], `\x`) && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
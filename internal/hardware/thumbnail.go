@@ -0,0 +1,226 @@
+package hardware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrInvalidThumbnail is returned for thumbnail options out of range.
+var ErrInvalidThumbnail = errors.New("invalid thumbnail options")
+
+// errNoThumbnailer is returned by a FileBrowser that can't make thumbnails.
+var errNoThumbnailer = errors.New("thumbnails can't be generated")
+
+// thumbnailCacheSize is how many re-encoded thumbnails are kept, about a
+// gallery screen or two.
+const thumbnailCacheSize = 32
+
+// ThumbnailOptions pick the size and quality of a thumbnail. Zero values
+// keep the stored width and use jpeg.DefaultQuality.
+type ThumbnailOptions struct {
+	MaxWidth uint16 `json:"max_width,omitempty"` // Only ever scales down
+	Quality  uint8  `json:"quality,omitempty"`   // 1 to 100
+}
+
+func (o ThumbnailOptions) validate() error {
+	if o.Quality > 100 {
+		return fmt.Errorf("%w: quality %d is over 100", ErrInvalidThumbnail, o.Quality)
+	}
+	return nil
+}
+
+func (o ThumbnailOptions) quality() int {
+	if o.Quality == 0 {
+		return jpeg.DefaultQuality
+	}
+	return int(o.Quality)
+}
+
+// scaledSize fits w x h within MaxWidth, keeping the aspect ratio.
+func (o ThumbnailOptions) scaledSize(w, h int) (int, int) {
+	if o.MaxWidth == 0 || int(o.MaxWidth) >= w {
+		return w, h
+	}
+	return int(o.MaxWidth), max(h*int(o.MaxWidth)/w, 1)
+}
+
+// Thumbnail is a recording's preview image, encoded as JPEG.
+type Thumbnail struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Data   []byte `json:"-"`
+}
+
+// thumbnailCache keeps the latest re-encoded thumbnails, dropping the
+// oldest first. Entries are keyed by source path and options, and stale
+// once the source file changes.
+type thumbnailCache struct {
+	mu      sync.Mutex
+	entries map[thumbnailKey]thumbnailEntry
+	order   []thumbnailKey
+}
+
+type thumbnailKey struct {
+	path string
+	opts ThumbnailOptions
+}
+
+type thumbnailEntry struct {
+	modTime time.Time
+	thumb   *Thumbnail
+}
+
+func (c *thumbnailCache) get(key thumbnailKey, modTime time.Time) *Thumbnail {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !e.modTime.Equal(modTime) {
+		return nil
+	}
+	return e.thumb
+}
+
+func (c *thumbnailCache) put(key thumbnailKey, modTime time.Time, thumb *Thumbnail) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[thumbnailKey]thumbnailEntry)
+	}
+	if _, ok := c.entries[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = thumbnailEntry{modTime: modTime, thumb: thumb}
+	for len(c.order) > thumbnailCacheSize {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// GenerateThumbnail (re)writes the Nth recording's .jpg sidecar, e.g. one
+// lost to a crash. The recording in progress has no frames to take yet.
+func (fb *FileBrowser) GenerateThumbnail(tag string, fileIndex uint32) error {
+	file, err := fb.GetRecordingDetails(tag, fileIndex)
+	if err != nil {
+		return err
+	}
+	return fb.generateThumbnail(file)
+}
+
+func (fb *FileBrowser) generateThumbnail(file *RecordingFileInfo) error {
+	if file.InProgress {
+		return ErrRecordingInProgress
+	}
+	if fb.makeThumbnail == nil {
+		return errNoThumbnailer
+	}
+	if err := fb.makeThumbnail(file.Path, file.ThumbnailPath); err != nil {
+		return err
+	}
+	slog.Info("Thumbnail generated", "file", file.FileName)
+	return nil
+}
+
+// thumbnailStat stats a recording's thumbnail, generating it first if it's
+// missing.
+func (fb *FileBrowser) thumbnailStat(file *RecordingFileInfo) (fs.FileInfo, error) {
+	info, err := os.Stat(file.ThumbnailPath)
+	if errors.Is(err, fs.ErrNotExist) && !file.InProgress {
+		if err := fb.generateThumbnail(file); err != nil {
+			return nil, err
+		}
+		info, err = os.Stat(file.ThumbnailPath)
+	}
+	return info, err
+}
+
+// GetThumbnail returns the Nth recording's .jpg sidecar, scaled down and
+// re-encoded as asked, generating a missing one first. Results are cached
+// until the sidecar changes.
+func (fb *FileBrowser) GetThumbnail(tag string, fileIndex uint32, opts ThumbnailOptions) (*Thumbnail, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	file, err := fb.GetRecordingDetails(tag, fileIndex)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fb.thumbnailStat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key := thumbnailKey{path: file.ThumbnailPath, opts: opts}
+	if thumb := fb.thumbs.get(key, info.ModTime()); thumb != nil {
+		return thumb, nil
+	}
+
+	data, err := os.ReadFile(file.ThumbnailPath)
+	if err != nil {
+		return nil, err
+	}
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("thumbnail of %s: %w", file.FileName, err)
+	}
+	thumb, err := encodeThumbnail(src, opts)
+	if err != nil {
+		return nil, err
+	}
+	fb.thumbs.put(key, info.ModTime(), thumb)
+	return thumb, nil
+}
+
+// encodeThumbnail scales src down to fit opts and encodes it.
+func encodeThumbnail(src image.Image, opts ThumbnailOptions) (*Thumbnail, error) {
+	b := src.Bounds()
+	w, h := opts.scaledSize(b.Dx(), b.Dy())
+	img := src
+	if w != b.Dx() {
+		img = downscale(src, w, h)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.quality()}); err != nil {
+		return nil, err
+	}
+	return &Thumbnail{Width: w, Height: h, Data: buf.Bytes()}, nil
+}
+
+// downscale shrinks src to w x h, averaging the source pixels under each
+// destination pixel so fine detail doesn't alias.
+func downscale(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/h, y0+1)
+		for x := range w {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(b.Min.X+(x+1)*b.Dx()/w, x0+1)
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+pr, g+pg, bl+pb, a+pa, n+1
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,208 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashDir is the hidden folder under RootPath holding deleted recordings.
+// Each delete goes into a batch folder named after its unix time:
+// .trash/<unix>/<tag>/vid_x.mp4 (+ sidecars)
+const TrashDir = ".trash"
+
+// sidecarExts are the files that travel with a recording's .mp4.
+var sidecarExts = []string{".imu", ".jpg", ".json"}
+
+// ErrNotInTrash is returned when restoring an id that isn't in the trash.
+var ErrNotInTrash = errors.New("recording not found in trash")
+
+// trashRecording moves a recording and its sidecars into the trash.
+func (fb *FileBrowser) trashRecording(tag, fileName string, batch time.Time) error {
+	src := fb.tagPath(tag)
+	dst := filepath.Join(fb.RootPath, TrashDir, strconv.FormatInt(batch.Unix(), 10), filepath.FromSlash(tag))
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(filepath.Join(src, fileName), filepath.Join(dst, fileName)); err != nil {
+		return err
+	}
+	moveSidecars(src, dst, fileName)
+
+	slog.Info("Recording moved to trash", "tag", tag, "file", fileName)
+	return nil
+}
+
+// RestoreRecording moves a trashed recording back into its tag folder.
+func (fb *FileBrowser) RestoreRecording(id uint32) error {
+	trash := filepath.Join(fb.RootPath, TrashDir)
+
+	var found string
+	err := filepath.WalkDir(trash, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".mp4") {
+			return err
+		}
+		if recordingID(d.Name()) == id {
+			found = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if found == "" {
+		return ErrNotInTrash
+	}
+
+	// .trash/<batch>/<tag...>/<file>
+	rel, _ := filepath.Rel(trash, filepath.Dir(found))
+	_, tag, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	fileName := filepath.Base(found)
+
+	dst := fb.tagPath(tag)
+	if _, err := os.Stat(filepath.Join(dst, fileName)); err == nil {
+		return fmt.Errorf("%s already exists in tag '%s'", fileName, tag)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(found, filepath.Join(dst, fileName)); err != nil {
+		return err
+	}
+	moveSidecars(filepath.Dir(found), dst, fileName)
+	removeEmptyDirs(filepath.Dir(found), trash)
+	fb.publishState(StateDisk)
+
+	slog.Info("Recording restored from trash", "tag", tag, "file", fileName)
+	return nil
+}
+
+// EmptyTrash permanently removes everything in the trash and returns how
+// many recordings were deleted.
+func (fb *FileBrowser) EmptyTrash() (uint32, error) {
+	return fb.removeTrashBatches(func(time.Time) bool { return true })
+}
+
+// PlanEmptyTrash lists the recordings EmptyTrash would remove.
+func (fb *FileBrowser) PlanEmptyTrash() (*DeletePlan, error) {
+	trash := filepath.Join(fb.RootPath, TrashDir)
+	plan := &DeletePlan{Tags: []string{}, Files: []PlannedFile{}}
+	err := filepath.WalkDir(trash, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".mp4") {
+			return err
+		}
+		// .trash/<unix>/<tag>/<file>
+		rel, err := filepath.Rel(trash, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		_, tag, _ := strings.Cut(filepath.ToSlash(rel), "/")
+		if !slices.Contains(plan.Tags, tag) {
+			plan.Tags = append(plan.Tags, tag)
+		}
+		plan.Files = append(plan.Files, PlannedFile{Tag: tag, FileName: d.Name()})
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	plan.Count = uint32(len(plan.Files))
+	return plan, nil
+}
+
+// TrashSizeMB returns the space taken by the trash.
+func (fb *FileBrowser) TrashSizeMB() uint32 {
+	var total int64
+	_ = filepath.WalkDir(filepath.Join(fb.RootPath, TrashDir), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return uint32(total / 1024 / 1024)
+}
+
+// purgeExpiredTrash drops batches older than TrashRetention (if set).
+func (fb *FileBrowser) purgeExpiredTrash() {
+	if fb.TrashRetention <= 0 {
+		return
+	}
+	op := fb.ops.Begin(OpTrashPurge)
+	defer op.End()
+
+	cutoff := time.Now().Add(-fb.TrashRetention)
+	n, err := fb.removeTrashBatches(func(t time.Time) bool { return t.Before(cutoff) })
+	if err != nil {
+		slog.Warn("Failed to purge expired trash", "err", err)
+	} else if n > 0 {
+		slog.Info("Purged expired trash", "recordings", n)
+	}
+}
+
+func (fb *FileBrowser) removeTrashBatches(match func(time.Time) bool) (uint32, error) {
+	trash := filepath.Join(fb.RootPath, TrashDir)
+	batches, err := os.ReadDir(trash)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var count uint32
+	for _, b := range batches {
+		ts, err := strconv.ParseInt(b.Name(), 10, 64)
+		if err != nil || !match(time.Unix(ts, 0)) {
+			continue
+		}
+
+		batchPath := filepath.Join(trash, b.Name())
+		_ = filepath.WalkDir(batchPath, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(d.Name(), ".mp4") {
+				count++
+			}
+			return nil
+		})
+		if err := os.RemoveAll(batchPath); err != nil {
+			return count, err
+		}
+	}
+	if count > 0 {
+		fb.publishState(StateDisk)
+	}
+	return count, nil
+}
+
+// moveSidecars moves the .imu/.jpg siblings of a recording, ignoring missing ones.
+func moveSidecars(srcDir, dstDir, fileName string) {
+	base := strings.TrimSuffix(fileName, ".mp4")
+	for _, ext := range sidecarExts {
+		err := os.Rename(filepath.Join(srcDir, base+ext), filepath.Join(dstDir, base+ext))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to move sidecar", "file", base+ext, "err", err)
+		}
+	}
+}
+
+// removeEmptyDirs removes dir and its parents up to (not including) stop
+// while they are empty.
+func removeEmptyDirs(dir, stop string) {
+	for dir != stop && strings.HasPrefix(dir, stop) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,32 @@
+package hardware
+
+import (
+	"strings"
+	"time"
+)
+
+// TriggerRecordDuration is how long a triggered recording runs.
+const TriggerRecordDuration = 30 * time.Second
+
+// TriggerTagPrefix starts the tag of every triggered recording.
+const TriggerTagPrefix = "trigger_"
+
+// triggerTag derives a tag from a trigger reason, keeping it a single
+// folder name: "Motion detected" becomes "trigger_motion_detected".
+func triggerTag(reason string) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '_'
+	}, reason)
+	slug = strings.Trim(slug, "_")
+
+	if slug == "" {
+		slug = "unknown"
+	}
+	return TriggerTagPrefix + slug
+}
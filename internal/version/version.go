@@ -0,0 +1,52 @@
+// Package version reports which build of the server is running.
+package version
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Version is set at build time, e.g.
+//
+//	go build -ldflags "-X blueowl-ble/internal/version.Version=1.4.0"
+//
+// Builds without it fall back to the VCS revision Go stamps into binaries.
+var Version string
+
+var fromBuildInfo = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision == "" {
+		return "dev"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return "dev-" + revision
+})
+
+// String returns Version, or else a version read from the build info.
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	return fromBuildInfo()
+}